@@ -0,0 +1,73 @@
+package csp
+
+import (
+	"cmp"
+	"slices"
+	"sync"
+)
+
+// ViolationCount is the number of reports of a violation recorded by an
+// Aggregator.
+type ViolationCount struct {
+	ViolatedDirective string
+	BlockedURI        string
+	Count             int
+}
+
+// violation is the key by which an Aggregator groups reports.
+type violation struct {
+	directive  string
+	blockedURI string
+}
+
+// Aggregator counts CSP violation reports grouped by violated directive and
+// blocked URI, showing the most common violations before a policy is
+// tightened or loosened. Its Record method may be passed to ReportHandler,
+// e.g. csp.ReportHandler(agg.Record). An Aggregator is safe for concurrent
+// use, and its zero value is ready to use.
+type Aggregator struct {
+	mu     sync.Mutex
+	counts map[violation]int
+}
+
+// NewAggregator returns an Aggregator with no reports recorded.
+func NewAggregator() *Aggregator {
+	return &Aggregator{counts: make(map[violation]int)}
+}
+
+// Record counts r. The effective directive of r is used if it has no
+// violated directive.
+func (a *Aggregator) Record(r Report) {
+	v := violation{directive: r.ViolatedDirective, blockedURI: r.BlockedURI}
+	if v.directive == "" {
+		v.directive = r.EffectiveDirective
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.counts == nil {
+		a.counts = make(map[violation]int)
+	}
+	a.counts[v]++
+}
+
+// Top returns the n most common violations recorded, most common first, or
+// every violation if fewer have been recorded. Violations with equal counts
+// are ordered by violated directive and then blocked URI.
+func (a *Aggregator) Top(n int) []ViolationCount {
+	a.mu.Lock()
+	vcs := make([]ViolationCount, 0, len(a.counts))
+	for v, count := range a.counts {
+		vcs = append(vcs, ViolationCount{ViolatedDirective: v.directive, BlockedURI: v.blockedURI, Count: count})
+	}
+	a.mu.Unlock()
+	slices.SortFunc(vcs, func(x, y ViolationCount) int {
+		if c := cmp.Compare(y.Count, x.Count); c != 0 {
+			return c
+		}
+		if c := cmp.Compare(x.ViolatedDirective, y.ViolatedDirective); c != 0 {
+			return c
+		}
+		return cmp.Compare(x.BlockedURI, y.BlockedURI)
+	})
+	return vcs[:max(0, min(n, len(vcs)))]
+}
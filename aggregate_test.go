@@ -0,0 +1,102 @@
+package csp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestAggregatorTop(t *testing.T) {
+	agg := NewAggregator()
+	record := func(directive, blockedURI string, times int) {
+		for i := 0; i < times; i++ {
+			agg.Record(Report{ViolatedDirective: directive, BlockedURI: blockedURI})
+		}
+	}
+	record("script-src-elem", "https://evil.example.com/x.js", 3)
+	record("img-src", "data", 5)
+	record("script-src-elem", "inline", 3)
+	record("style-src-elem", "inline", 1)
+	agg.Record(Report{EffectiveDirective: "img-src", BlockedURI: "data"})
+	cases := map[string]struct {
+		n    int
+		want []ViolationCount
+	}{
+		"top two": {
+			n: 2,
+			want: []ViolationCount{
+				{ViolatedDirective: "img-src", BlockedURI: "data", Count: 6},
+				{ViolatedDirective: "script-src-elem", BlockedURI: "https://evil.example.com/x.js", Count: 3},
+			},
+		},
+		"all": {
+			n: 10,
+			want: []ViolationCount{
+				{ViolatedDirective: "img-src", BlockedURI: "data", Count: 6},
+				{ViolatedDirective: "script-src-elem", BlockedURI: "https://evil.example.com/x.js", Count: 3},
+				{ViolatedDirective: "script-src-elem", BlockedURI: "inline", Count: 3},
+				{ViolatedDirective: "style-src-elem", BlockedURI: "inline", Count: 1},
+			},
+		},
+		"none": {
+			n:    0,
+			want: []ViolationCount{},
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			if got := agg.Top(c.n); !reflect.DeepEqual(got, c.want) {
+				t.Fatalf(errorString, got, c.want)
+			}
+		})
+	}
+}
+
+func TestAggregatorConcurrent(t *testing.T) {
+	const goroutines, reports = 8, 100
+	agg := NewAggregator()
+	var wg sync.WaitGroup
+	for i := 0; i < goroutines; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < reports; j++ {
+				agg.Record(Report{ViolatedDirective: "script-src-elem", BlockedURI: "inline"})
+				agg.Top(1)
+			}
+		}()
+	}
+	wg.Wait()
+	want := []ViolationCount{{ViolatedDirective: "script-src-elem", BlockedURI: "inline", Count: goroutines * reports}}
+	if got := agg.Top(1); !reflect.DeepEqual(got, want) {
+		t.Fatalf(errorString, got, want)
+	}
+}
+
+func TestAggregatorZero(t *testing.T) {
+	var agg Aggregator
+	if got := agg.Top(1); len(got) != 0 {
+		t.Fatalf(errorString, got, "no violations")
+	}
+	agg.Record(Report{ViolatedDirective: "img-src", BlockedURI: "data"})
+	want := []ViolationCount{{ViolatedDirective: "img-src", BlockedURI: "data", Count: 1}}
+	if got := agg.Top(1); !reflect.DeepEqual(got, want) {
+		t.Fatalf(errorString, got, want)
+	}
+}
+
+func TestAggregatorReportHandler(t *testing.T) {
+	agg := NewAggregator()
+	handler := ReportHandler(agg.Record)
+	for i := 0; i < 2; i++ {
+		r := httptest.NewRequest(http.MethodPost, "/csp-reports", strings.NewReader(legacyReport))
+		r.Header.Set("Content-Type", "application/csp-report")
+		handler.ServeHTTP(httptest.NewRecorder(), r)
+	}
+	if got := agg.Top(1); len(got) != 1 || got[0].Count != 2 {
+		t.Fatalf(errorString, got, "one violation counted twice")
+	}
+}
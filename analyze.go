@@ -0,0 +1,42 @@
+package csp
+
+import "slices"
+
+// AllowsInlineScript returns true if ds allows inline scripts to run, either
+// as <script> elements or as event handler attributes. The effective sources
+// of script-src-elem and script-src-attr are used, see Effective, so that
+// script-src and default-src are considered when they are not set. Inline
+// scripts are allowed where the sources contain 'unsafe-inline' and no
+// nonce-source, hash-source, or 'strict-dynamic', any of which makes user
+// agents ignore 'unsafe-inline', or where no directive restricts scripts.
+func AllowsInlineScript(ds Directives) bool {
+	for _, dName := range []string{"script-src-elem", "script-src-attr"} {
+		sources, ok := effective(ds, dName)
+		if !ok {
+			return true
+		}
+		if slices.Contains(sources, SourceUnsafeInline) && !slices.ContainsFunc(sources, func(s string) bool {
+			return s == SourceStrictDynamic || isNonceSource(s) || isHashSource(s)
+		}) {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsEval returns true if ds allows scripts to evaluate strings as code,
+// e.g. with eval(), i.e. if the effective sources of script-src, see
+// Effective, contain 'unsafe-eval' or no directive restricts scripts.
+func AllowsEval(ds Directives) bool {
+	sources, ok := effective(ds, "script-src")
+	return !ok || slices.Contains(sources, SourceUnsafeEval)
+}
+
+// AllowsWasmEval returns true if ds allows WebAssembly to be compiled, i.e. if
+// the effective sources of script-src, see Effective, contain
+// 'wasm-unsafe-eval' or 'unsafe-eval', which implies it, or no directive
+// restricts scripts.
+func AllowsWasmEval(ds Directives) bool {
+	sources, ok := effective(ds, "script-src")
+	return !ok || slices.Contains(sources, SourceWasmUnsafeEval) || slices.Contains(sources, SourceUnsafeEval)
+}
@@ -0,0 +1,118 @@
+package csp
+
+import "testing"
+
+func TestAllowsInlineScript(t *testing.T) {
+	cases := map[string]struct {
+		directives Directives
+		want       bool
+	}{
+		"unsafe-inline alone": {
+			directives: Directives{ScriptSrc: []string{"self", "unsafe-inline"}},
+			want:       true,
+		},
+		"unsafe-inline with nonce": {
+			directives: Directives{ScriptSrc: []string{"unsafe-inline", NonceSource("r4nd0m")}},
+			want:       false,
+		},
+		"unsafe-inline with hash": {
+			directives: Directives{ScriptSrc: []string{"unsafe-inline", "'sha256-abc='"}},
+			want:       false,
+		},
+		"unsafe-inline with strict-dynamic": {
+			directives: Directives{ScriptSrc: []string{"unsafe-inline", "strict-dynamic"}},
+			want:       false,
+		},
+		"no unsafe-inline": {
+			directives: Directives{ScriptSrc: []string{"self"}},
+			want:       false,
+		},
+		"default-src fallback": {
+			directives: Directives{DefaultSrc: []string{"self", "unsafe-inline"}},
+			want:       true,
+		},
+		"script-src overrides default-src": {
+			directives: Directives{
+				DefaultSrc: []string{"self", "unsafe-inline"},
+				ScriptSrc:  []string{"self"},
+			},
+			want: false,
+		},
+		"script-src-attr": {
+			directives: Directives{
+				ScriptSrc:     []string{"self"},
+				ScriptSrcAttr: []string{"unsafe-inline"},
+			},
+			want: true,
+		},
+		"unrestricted": {
+			directives: Directives{ImgSrc: []string{"self"}},
+			want:       true,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			if got := AllowsInlineScript(c.directives); got != c.want {
+				t.Fatalf(errorString, got, c.want)
+			}
+		})
+	}
+}
+
+func TestAllowsEval(t *testing.T) {
+	cases := map[string]struct {
+		directives Directives
+		eval, wasm bool
+	}{
+		"neither": {
+			directives: Directives{ScriptSrc: []string{"self"}},
+		},
+		"unsafe-eval": {
+			directives: Directives{ScriptSrc: []string{"self", "unsafe-eval"}},
+			eval:       true,
+			wasm:       true,
+		},
+		"wasm-unsafe-eval": {
+			directives: Directives{ScriptSrc: []string{"self", "wasm-unsafe-eval"}},
+			wasm:       true,
+		},
+		"both": {
+			directives: Directives{ScriptSrc: []string{"'unsafe-eval'", "'wasm-unsafe-eval'"}},
+			eval:       true,
+			wasm:       true,
+		},
+		"default-src fallback": {
+			directives: Directives{DefaultSrc: []string{"self", "unsafe-eval"}},
+			eval:       true,
+			wasm:       true,
+		},
+		"script-src overrides default-src": {
+			directives: Directives{
+				DefaultSrc: []string{"unsafe-eval"},
+				ScriptSrc:  []string{"wasm-unsafe-eval"},
+			},
+			wasm: true,
+		},
+		"script-src-elem ignored": {
+			directives: Directives{
+				ScriptSrc:     []string{"self"},
+				ScriptSrcElem: []string{"unsafe-eval"},
+			},
+		},
+		"unrestricted": {
+			directives: Directives{ImgSrc: []string{"self"}},
+			eval:       true,
+			wasm:       true,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			if got := AllowsEval(c.directives); got != c.eval {
+				t.Fatalf(errorString, got, c.eval)
+			}
+			if got := AllowsWasmEval(c.directives); got != c.wasm {
+				t.Fatalf(errorString, got, c.wasm)
+			}
+		})
+	}
+}
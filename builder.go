@@ -0,0 +1,218 @@
+package csp
+
+// Builder assembles Directives through chainable methods. Methods for
+// source-list directives append to any sources already added, so a policy may
+// be built incrementally; methods for string-valued directives replace the
+// current value.
+type Builder struct {
+	ds Directives
+}
+
+// NewBuilder returns a Builder with no directives set.
+func NewBuilder() *Builder {
+	return &Builder{}
+}
+
+// Build returns a copy of the Directives assembled by b, which is unaffected
+// by further calls to b.
+func (b *Builder) Build() Directives {
+	return b.ds.Clone()
+}
+
+// Policy returns the policy string of the Directives assembled by b.
+func (b *Builder) Policy() string {
+	return Policy(b.ds)
+}
+
+// BaseURI appends sources to the base-uri directive.
+func (b *Builder) BaseURI(sources ...string) *Builder {
+	b.ds.BaseURI = append(b.ds.BaseURI, sources...)
+	return b
+}
+
+// BlockAllMixedContent sets the valueless block-all-mixed-content directive.
+func (b *Builder) BlockAllMixedContent() *Builder {
+	b.ds.BlockAllMixedContent = true
+	return b
+}
+
+// ChildSrc appends sources to the child-src directive.
+func (b *Builder) ChildSrc(sources ...string) *Builder {
+	b.ds.ChildSrc = append(b.ds.ChildSrc, sources...)
+	return b
+}
+
+// ConnectSrc appends sources to the connect-src directive.
+func (b *Builder) ConnectSrc(sources ...string) *Builder {
+	b.ds.ConnectSrc = append(b.ds.ConnectSrc, sources...)
+	return b
+}
+
+// DefaultSrc appends sources to the default-src directive.
+func (b *Builder) DefaultSrc(sources ...string) *Builder {
+	b.ds.DefaultSrc = append(b.ds.DefaultSrc, sources...)
+	return b
+}
+
+// FencedFrameSrc appends sources to the fenced-frame-src directive.
+func (b *Builder) FencedFrameSrc(sources ...string) *Builder {
+	b.ds.FencedFrameSrc = append(b.ds.FencedFrameSrc, sources...)
+	return b
+}
+
+// FontSrc appends sources to the font-src directive.
+func (b *Builder) FontSrc(sources ...string) *Builder {
+	b.ds.FontSrc = append(b.ds.FontSrc, sources...)
+	return b
+}
+
+// FormAction appends sources to the form-action directive.
+func (b *Builder) FormAction(sources ...string) *Builder {
+	b.ds.FormAction = append(b.ds.FormAction, sources...)
+	return b
+}
+
+// FrameAncestors appends sources to the frame-ancestors directive.
+func (b *Builder) FrameAncestors(sources ...string) *Builder {
+	b.ds.FrameAncestors = append(b.ds.FrameAncestors, sources...)
+	return b
+}
+
+// FrameSrc appends sources to the frame-src directive.
+func (b *Builder) FrameSrc(sources ...string) *Builder {
+	b.ds.FrameSrc = append(b.ds.FrameSrc, sources...)
+	return b
+}
+
+// ImgSrc appends sources to the img-src directive.
+func (b *Builder) ImgSrc(sources ...string) *Builder {
+	b.ds.ImgSrc = append(b.ds.ImgSrc, sources...)
+	return b
+}
+
+// ManifestSrc appends sources to the manifest-src directive.
+func (b *Builder) ManifestSrc(sources ...string) *Builder {
+	b.ds.ManifestSrc = append(b.ds.ManifestSrc, sources...)
+	return b
+}
+
+// MediaSrc appends sources to the media-src directive.
+func (b *Builder) MediaSrc(sources ...string) *Builder {
+	b.ds.MediaSrc = append(b.ds.MediaSrc, sources...)
+	return b
+}
+
+// NavigateTo appends sources to the navigate-to directive.
+func (b *Builder) NavigateTo(sources ...string) *Builder {
+	b.ds.NavigateTo = append(b.ds.NavigateTo, sources...)
+	return b
+}
+
+// ObjectSrc appends sources to the object-src directive.
+func (b *Builder) ObjectSrc(sources ...string) *Builder {
+	b.ds.ObjectSrc = append(b.ds.ObjectSrc, sources...)
+	return b
+}
+
+// PluginTypes appends MIME types to the plugin-types directive.
+func (b *Builder) PluginTypes(types ...string) *Builder {
+	b.ds.PluginTypes = append(b.ds.PluginTypes, types...)
+	return b
+}
+
+// PrefetchSrc appends sources to the prefetch-src directive.
+func (b *Builder) PrefetchSrc(sources ...string) *Builder {
+	b.ds.PrefetchSrc = append(b.ds.PrefetchSrc, sources...)
+	return b
+}
+
+// ReportTo sets the value of the report-to directive.
+func (b *Builder) ReportTo(value string) *Builder {
+	b.ds.ReportTo = value
+	return b
+}
+
+// ReportURI appends sources to the report-uri directive.
+func (b *Builder) ReportURI(sources ...string) *Builder {
+	b.ds.ReportURI = append(b.ds.ReportURI, sources...)
+	return b
+}
+
+// RequireTrustedTypesFor appends sources to the require-trusted-types-for directive.
+func (b *Builder) RequireTrustedTypesFor(sources ...string) *Builder {
+	b.ds.RequireTrustedTypesFor = append(b.ds.RequireTrustedTypesFor, sources...)
+	return b
+}
+
+// Sandbox sets the value of the sandbox directive.
+func (b *Builder) Sandbox(value string) *Builder {
+	b.ds.Sandbox = value
+	return b
+}
+
+// SandboxEmpty sets the valueless sandbox directive, the most restrictive
+// sandbox, used when no sandbox value is set.
+func (b *Builder) SandboxEmpty() *Builder {
+	b.ds.SandboxEmpty = true
+	return b
+}
+
+// ScriptSrc appends sources to the script-src directive.
+func (b *Builder) ScriptSrc(sources ...string) *Builder {
+	b.ds.ScriptSrc = append(b.ds.ScriptSrc, sources...)
+	return b
+}
+
+// ScriptSrcAttr appends sources to the script-src-attr directive.
+func (b *Builder) ScriptSrcAttr(sources ...string) *Builder {
+	b.ds.ScriptSrcAttr = append(b.ds.ScriptSrcAttr, sources...)
+	return b
+}
+
+// ScriptSrcElem appends sources to the script-src-elem directive.
+func (b *Builder) ScriptSrcElem(sources ...string) *Builder {
+	b.ds.ScriptSrcElem = append(b.ds.ScriptSrcElem, sources...)
+	return b
+}
+
+// StyleSrc appends sources to the style-src directive.
+func (b *Builder) StyleSrc(sources ...string) *Builder {
+	b.ds.StyleSrc = append(b.ds.StyleSrc, sources...)
+	return b
+}
+
+// StyleSrcAttr appends sources to the style-src-attr directive.
+func (b *Builder) StyleSrcAttr(sources ...string) *Builder {
+	b.ds.StyleSrcAttr = append(b.ds.StyleSrcAttr, sources...)
+	return b
+}
+
+// StyleSrcElem appends sources to the style-src-elem directive.
+func (b *Builder) StyleSrcElem(sources ...string) *Builder {
+	b.ds.StyleSrcElem = append(b.ds.StyleSrcElem, sources...)
+	return b
+}
+
+// TrustedTypes appends sources to the trusted-types directive.
+func (b *Builder) TrustedTypes(sources ...string) *Builder {
+	b.ds.TrustedTypes = append(b.ds.TrustedTypes, sources...)
+	return b
+}
+
+// UpgradeInsecureRequests sets the valueless upgrade-insecure-requests directive.
+func (b *Builder) UpgradeInsecureRequests() *Builder {
+	b.ds.UpgradeInsecureRequests = true
+	return b
+}
+
+// WebRTC sets the value of the webrtc directive.
+func (b *Builder) WebRTC(value string) *Builder {
+	b.ds.WebRTC = value
+	return b
+}
+
+// WorkerSrc appends sources to the worker-src directive.
+func (b *Builder) WorkerSrc(sources ...string) *Builder {
+	b.ds.WorkerSrc = append(b.ds.WorkerSrc, sources...)
+	return b
+}
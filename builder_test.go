@@ -0,0 +1,81 @@
+package csp
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBuilder(t *testing.T) {
+	cases := map[string]struct {
+		builder *Builder
+		want    Directives
+	}{
+		"empty": {
+			builder: NewBuilder(),
+			want:    Directives{},
+		},
+		"chained": {
+			builder: NewBuilder().
+				DefaultSrc(SourceSelf).
+				ScriptSrc(SourceSelf, "https://cdn.example.com").
+				ReportTo("csp-endpoint").
+				UpgradeInsecureRequests(),
+			want: Directives{
+				DefaultSrc:              []string{SourceSelf},
+				ScriptSrc:               []string{SourceSelf, "https://cdn.example.com"},
+				ReportTo:                "csp-endpoint",
+				UpgradeInsecureRequests: true,
+			},
+		},
+		"appends": {
+			builder: NewBuilder().
+				ScriptSrc(SourceSelf).
+				ScriptSrc("https://a.example.com").
+				ScriptSrc("https://b.example.com"),
+			want: Directives{
+				ScriptSrc: []string{SourceSelf, "https://a.example.com", "https://b.example.com"},
+			},
+		},
+		"empty sandbox": {
+			builder: NewBuilder().SandboxEmpty(),
+			want: Directives{
+				SandboxEmpty: true,
+			},
+		},
+		"replaces strings": {
+			builder: NewBuilder().Sandbox("allow-forms").Sandbox("allow-scripts"),
+			want: Directives{
+				Sandbox: "allow-scripts",
+			},
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			if got := c.builder.Build(); !reflect.DeepEqual(got, c.want) {
+				t.Fatalf(directivesErrorString, got, c.want)
+			}
+		})
+	}
+}
+
+func TestBuilderPolicy(t *testing.T) {
+	b := NewBuilder()
+	for _, cdn := range []string{"https://a.example.com", "https://b.example.com"} {
+		b.ScriptSrc(cdn)
+	}
+	got := b.DefaultSrc("self").Policy()
+	want := "default-src 'self'; script-src https://a.example.com https://b.example.com;"
+	if got != want {
+		t.Fatalf(errorString, got, want)
+	}
+}
+
+func TestBuilderBuildIsolated(t *testing.T) {
+	b := NewBuilder().ScriptSrc(SourceSelf)
+	built := b.Build()
+	b.ScriptSrc("https://example.com")
+	built.ScriptSrc[0] = SourceNone
+	if got, want := b.Build().ScriptSrc, []string{SourceSelf, "https://example.com"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf(errorString, got, want)
+	}
+}
@@ -0,0 +1,357 @@
+package csp
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Equal returns true if a and b describe the same policy. Sources are
+// canonicalized and compared as sets, so their order and duplicates do not
+// matter, and string-valued directives are compared after canonicalization.
+// A directive that is not set is not equal to one set to 'none'. Extra
+// directives are compared verbatim, and an unset sandbox is not equal to an
+// empty one, see SandboxEmpty.
+func Equal(a, b Directives) bool {
+	aVal, bVal := reflect.ValueOf(a), reflect.ValueOf(b)
+	for i := 0; i < aVal.NumField(); i++ {
+		aField, bField := aVal.Field(i), bVal.Field(i)
+		name := aVal.Type().Field(i).Name
+		if _, ok := CName[name]; !ok {
+			continue
+		}
+		switch aField.Kind() {
+		case reflect.Slice:
+			as := canonSources(name, aField.Interface().([]string))
+			bs := canonSources(name, bField.Interface().([]string))
+			if !sameSet(as, bs) {
+				return false
+			}
+		case reflect.String:
+			if canon(aField.String()) != canon(bField.String()) {
+				return false
+			}
+		case reflect.Bool:
+			if aField.Bool() != bField.Bool() {
+				return false
+			}
+		}
+	}
+	if bareSandbox(a) != bareSandbox(b) || len(a.Extra) != len(b.Extra) {
+		return false
+	}
+	for dName, as := range a.Extra {
+		if bs, ok := b.Extra[dName]; !ok || !sameSet(as, bs) {
+			return false
+		}
+	}
+	return true
+}
+
+// sameSet returns true if a and b contain the same strings, ignoring order and
+// duplicates.
+func sameSet(a, b []string) bool {
+	as, bs := toSet(a), toSet(b)
+	if len(as) != len(bs) {
+		return false
+	}
+	for s := range as {
+		if !bs[s] {
+			return false
+		}
+	}
+	return true
+}
+
+// toSet returns the strings in ss as a set.
+func toSet(ss []string) map[string]bool {
+	set := make(map[string]bool, len(ss))
+	for _, s := range ss {
+		set[s] = true
+	}
+	return set
+}
+
+// SourceChange describes how a directive differs between two policies. For
+// source-list directives, Added and Removed hold the canonicalized sources
+// present only in the new and old policy respectively. For string-valued and
+// valueless directives, Old and New hold the canonicalized values, with
+// valueless directives represented as "true" or "false"; a change between an
+// unset and an empty sandbox, see SandboxEmpty, is represented likewise.
+type SourceChange struct {
+	Added   []string
+	Removed []string
+	Old     string
+	New     string
+}
+
+// String returns a human-readable summary of c, e.g.
+// "+https://cdn.new.com -https://cdn.old.com" or "'allow' -> 'block'".
+func (c SourceChange) String() string {
+	if len(c.Added) == 0 && len(c.Removed) == 0 {
+		return fmt.Sprintf("%q -> %q", c.Old, c.New)
+	}
+	changes := make([]string, 0, len(c.Added)+len(c.Removed))
+	for _, s := range c.Added {
+		changes = append(changes, "+"+s)
+	}
+	for _, s := range c.Removed {
+		changes = append(changes, "-"+s)
+	}
+	return strings.Join(changes, " ")
+}
+
+// Diff returns the changes from old to new keyed by directive name. Only
+// directives that differ are included; a directive present in only one of
+// the policies is reported as wholly added or removed.
+func Diff(old, new Directives) map[string]SourceChange {
+	diff := make(map[string]SourceChange)
+	oVal, nVal := reflect.ValueOf(old), reflect.ValueOf(new)
+	for i := 0; i < oVal.NumField(); i++ {
+		oField, nField := oVal.Field(i), nVal.Field(i)
+		name := oVal.Type().Field(i).Name
+		dName, ok := CName[name]
+		if !ok {
+			continue
+		}
+		switch oField.Kind() {
+		case reflect.Slice:
+			os := dedup(canonSources(name, oField.Interface().([]string)))
+			ns := dedup(canonSources(name, nField.Interface().([]string)))
+			added, removed := difference(ns, os), difference(os, ns)
+			if len(added) > 0 || len(removed) > 0 {
+				diff[dName] = SourceChange{Added: added, Removed: removed}
+			}
+		case reflect.String:
+			if o, n := canon(oField.String()), canon(nField.String()); o != n {
+				diff[dName] = SourceChange{Old: o, New: n}
+			}
+		case reflect.Bool:
+			if o, n := oField.Bool(), nField.Bool(); o != n {
+				diff[dName] = SourceChange{Old: strconv.FormatBool(o), New: strconv.FormatBool(n)}
+			}
+		}
+	}
+	if o, n := bareSandbox(old), bareSandbox(new); o != n {
+		if _, ok := diff["sandbox"]; !ok {
+			diff["sandbox"] = SourceChange{Old: strconv.FormatBool(o), New: strconv.FormatBool(n)}
+		}
+	}
+	for _, extra := range []map[string][]string{old.Extra, new.Extra} {
+		for dName := range extra {
+			os, ns := old.Extra[dName], new.Extra[dName]
+			added, removed := difference(ns, os), difference(os, ns)
+			if len(added) > 0 || len(removed) > 0 {
+				diff[dName] = SourceChange{Added: added, Removed: removed}
+			}
+		}
+	}
+	return diff
+}
+
+// difference returns the strings in a that are not in b, in the order of a.
+func difference(a, b []string) []string {
+	bs := toSet(b)
+	var diff []string
+	for _, s := range a {
+		if !bs[s] {
+			diff = append(diff, s)
+		}
+	}
+	return diff
+}
+
+// IsSubset returns true if child is no more permissive than parent, i.e. if
+// every source allowed by child is also allowed by parent for each
+// source-list directive. Directives which are not set are resolved through
+// their default-src fallbacks first, and an unrestricted parent directive
+// allows anything.
+//
+// The comparison makes these simplifications:
+//   - A child source is allowed if parent contains the same source, a "*"
+//     covering a host-source or a scheme-source other than data:, blob:, and
+//     filesystem:, a scheme-source such as "https:" covering host-sources of
+//     that scheme, a "*" host such as "https://*" covering any host, or a "*."
+//     wildcard host covering the child's host.
+//   - Keyword, nonce, and hash sources are only allowed by the same source.
+//   - Ports and paths must match exactly when parent specifies them, except
+//     that a ":*" port in parent matches any port; default ports are not
+//     inferred from schemes.
+//   - Directives which are not source lists, such as sandbox, are ignored.
+func IsSubset(child, parent Directives) bool {
+	for _, f := range fields {
+		if f.kind != reflect.Slice || slices.Contains(nonSourceLists, f.dName) {
+			continue
+		}
+		ps, restricted := effective(parent, f.dName)
+		if !restricted {
+			continue
+		}
+		cs, restricted := effective(child, f.dName)
+		if !restricted {
+			return false
+		}
+		for _, c := range cs {
+			if c != SourceNone && !slices.ContainsFunc(ps, func(p string) bool { return covers(p, c) }) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Intersect returns a policy which allows only what both a and b allow,
+// predicting the effect of enforcing both, e.g. when a framework and an
+// application each send a policy. For each source-list directive, the
+// effective sources of a and b are resolved through their fallbacks, see
+// Effective, and the result keeps every source of either which the other
+// covers, using the same simplified matching as IsSubset; a directive allowing
+// nothing becomes 'none'. The result is then minified, see Minify.
+//
+// Other directives are combined as follows: valueless directives are set if
+// set in either, sandbox keeps the tokens allowed by both when both are set,
+// becoming an empty sandbox if there are none, webrtc is 'block' if either
+// blocks, report-to and lists which are not sources, such as report-uri and
+// trusted-types, are taken from a unless empty, and Extra directives are taken
+// verbatim from both, a winning.
+func Intersect(a, b Directives) Directives {
+	var ds Directives
+	val := reflect.ValueOf(&ds).Elem()
+	for _, f := range fields {
+		if f.kind != reflect.Slice || slices.Contains(nonSourceLists, f.dName) {
+			continue
+		}
+		as, aOK := effective(a, f.dName)
+		bs, bOK := effective(b, f.dName)
+		var sources []string
+		switch {
+		case !aOK && !bOK:
+			continue
+		case !aOK:
+			sources = bs
+		case !bOK:
+			sources = as
+		default:
+			sources = intersectSources(as, bs)
+		}
+		val.Field(f.index).Set(reflect.ValueOf(sources))
+	}
+	for _, dName := range nonSourceLists {
+		f := fields[slices.IndexFunc(fields, func(f field) bool { return f.dName == dName })]
+		src := reflect.ValueOf(a)
+		if src.Field(f.index).Len() == 0 {
+			src = reflect.ValueOf(b)
+		}
+		val.Field(f.index).Set(reflect.ValueOf(slices.Clone(src.Field(f.index).Interface().([]string))))
+	}
+	ds.BlockAllMixedContent = a.BlockAllMixedContent || b.BlockAllMixedContent
+	ds.UpgradeInsecureRequests = a.UpgradeInsecureRequests || b.UpgradeInsecureRequests
+	ds.ReportTo = a.ReportTo
+	if ds.ReportTo == "" {
+		ds.ReportTo = b.ReportTo
+	}
+	ds.Sandbox, ds.SandboxEmpty = intersectSandbox(a, b)
+	ds.WebRTC = a.WebRTC
+	if ds.WebRTC == "" || canon(b.WebRTC) == WebRTCBlock {
+		ds.WebRTC = b.WebRTC
+	}
+	for _, extra := range []map[string][]string{b.Extra, a.Extra} {
+		for dName, sources := range extra {
+			if ds.Extra == nil {
+				ds.Extra = make(map[string][]string)
+			}
+			ds.Extra[dName] = slices.Clone(sources)
+		}
+	}
+	return Minify(ds)
+}
+
+// intersectSources returns the canonicalized sources of as and bs which are
+// covered by a source of the other, or 'none' if there are none.
+func intersectSources(as, bs []string) []string {
+	var sources []string
+	for _, pair := range [][2][]string{{as, bs}, {bs, as}} {
+		for _, c := range pair[0] {
+			if c != SourceNone && slices.ContainsFunc(pair[1], func(p string) bool { return covers(p, c) }) {
+				sources = append(sources, c)
+			}
+		}
+	}
+	if len(sources) == 0 {
+		return []string{SourceNone}
+	}
+	return dedup(sources)
+}
+
+// intersectSandbox returns the sandbox tokens allowed by both a and b, and
+// whether the result is an empty sandbox, see SandboxEmpty. An unset sandbox
+// applies no restriction, so the sandbox of the other applies.
+func intersectSandbox(a, b Directives) (string, bool) {
+	switch {
+	case bareSandbox(a) || bareSandbox(b):
+		return "", true
+	case canon(a.Sandbox) == "":
+		return b.Sandbox, false
+	case canon(b.Sandbox) == "":
+		return a.Sandbox, false
+	}
+	bTokens := strings.Fields(strings.ToLower(b.Sandbox))
+	var tokens []string
+	for _, t := range strings.Fields(strings.ToLower(a.Sandbox)) {
+		if slices.Contains(bTokens, t) && !slices.Contains(tokens, t) {
+			tokens = append(tokens, t)
+		}
+	}
+	return strings.Join(tokens, " "), len(tokens) == 0
+}
+
+// covers returns true if the canonicalized source p allows everything allowed
+// by the canonicalized source c, within the simplifications of IsSubset.
+func covers(p, c string) bool {
+	switch {
+	case p == c:
+		return true
+	case strings.HasPrefix(p, "'") || strings.HasPrefix(c, "'") && c != SourceSelf:
+		return false
+	case p == "*":
+		return c == SourceSelf || !slices.Contains([]string{SchemeData, SchemeBlob, SchemeFilesystem}, strings.ToLower(c))
+	case c == SourceSelf:
+		return false
+	case isSchemeSource(p):
+		scheme, _, ok := strings.Cut(c, "://")
+		return ok && strings.EqualFold(scheme+":", p)
+	case isSchemeSource(c):
+		return false
+	}
+	pScheme, pHost, pPort, pPath := splitHostSource(p)
+	cScheme, cHost, cPort, cPath := splitHostSource(c)
+	switch {
+	case pScheme != "" && !strings.EqualFold(pScheme, cScheme),
+		pPort != "" && pPort != "*" && pPort != cPort,
+		pPath != "" && pPath != cPath:
+		return false
+	}
+	pHost, cHost = strings.ToLower(pHost), strings.ToLower(cHost)
+	if pHost == "*" {
+		return true
+	}
+	if domain, ok := strings.CutPrefix(pHost, "*."); ok {
+		return strings.HasSuffix(cHost, "."+domain)
+	}
+	return pHost == cHost
+}
+
+// splitHostSource returns the scheme, host, port, and path of the host-source
+// s, each empty if not present.
+func splitHostSource(s string) (scheme, host, port, path string) {
+	if sc, after, ok := strings.Cut(s, "://"); ok {
+		scheme, s = sc, after
+	}
+	if i := strings.IndexByte(s, '/'); i >= 0 {
+		s, path = s[:i], s[i:]
+	}
+	host, port, _ = strings.Cut(s, ":")
+	return scheme, host, port, path
+}
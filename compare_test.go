@@ -0,0 +1,349 @@
+package csp
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEqual(t *testing.T) {
+	cases := map[string]struct {
+		a, b Directives
+		want bool
+	}{
+		"empty": {
+			want: true,
+		},
+		"reordered sources": {
+			a:    Directives{ScriptSrc: []string{"'self'", "https://x.example.com"}},
+			b:    Directives{ScriptSrc: []string{"https://x.example.com", "self"}},
+			want: true,
+		},
+		"duplicate sources": {
+			a:    Directives{ScriptSrc: []string{"'self'", "self"}},
+			b:    Directives{ScriptSrc: []string{"'self'"}},
+			want: true,
+		},
+		"different sources": {
+			a:    Directives{ScriptSrc: []string{"'self'"}},
+			b:    Directives{ScriptSrc: []string{"'self'", "https://x.example.com"}},
+			want: false,
+		},
+		"different directives": {
+			a:    Directives{ScriptSrc: []string{"'self'"}},
+			b:    Directives{StyleSrc: []string{"'self'"}},
+			want: false,
+		},
+		"unset and none": {
+			a:    Directives{ObjectSrc: []string{}},
+			b:    Directives{ObjectSrc: []string{"'none'"}},
+			want: false,
+		},
+		"canonical strings": {
+			a:    Directives{WebRTC: "block", ReportTo: " csp-endpoint "},
+			b:    Directives{WebRTC: WebRTCBlock, ReportTo: "csp-endpoint"},
+			want: true,
+		},
+		"equal extra": {
+			a:    Directives{Extra: map[string][]string{"foo-src": {"a", "b"}}},
+			b:    Directives{Extra: map[string][]string{"foo-src": {"b", "a"}}},
+			want: true,
+		},
+		"different extra": {
+			a:    Directives{Extra: map[string][]string{"foo-src": {"a"}}},
+			b:    Directives{Extra: map[string][]string{"bar-src": {"a"}}},
+			want: false,
+		},
+		"different booleans": {
+			a:    Directives{UpgradeInsecureRequests: true},
+			b:    Directives{},
+			want: false,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			if got := Equal(c.a, c.b); got != c.want {
+				t.Fatalf(errorString, got, c.want)
+			}
+		})
+	}
+}
+
+func TestDiff(t *testing.T) {
+	cases := map[string]struct {
+		old, new Directives
+		want     map[string]SourceChange
+	}{
+		"equal": {
+			old:  Directives{ScriptSrc: []string{"self", "https://x.example.com"}},
+			new:  Directives{ScriptSrc: []string{"https://x.example.com", "'self'"}},
+			want: map[string]SourceChange{},
+		},
+		"changed sources": {
+			old: Directives{ScriptSrc: []string{"'self'", "https://cdn.old.com"}},
+			new: Directives{ScriptSrc: []string{"'self'", "https://cdn.new.com"}},
+			want: map[string]SourceChange{
+				"script-src": {Added: []string{"https://cdn.new.com"}, Removed: []string{"https://cdn.old.com"}},
+			},
+		},
+		"wholly added and removed": {
+			old: Directives{ImgSrc: []string{"'self'", "data:"}},
+			new: Directives{FontSrc: []string{"'self'"}},
+			want: map[string]SourceChange{
+				"font-src": {Added: []string{"'self'"}},
+				"img-src":  {Removed: []string{"'self'", "data:"}},
+			},
+		},
+		"extra": {
+			old: Directives{Extra: map[string][]string{"foo-src": {"a"}}},
+			new: Directives{Extra: map[string][]string{"foo-src": {"b"}, "bar-src": {"c"}}},
+			want: map[string]SourceChange{
+				"foo-src": {Added: []string{"b"}, Removed: []string{"a"}},
+				"bar-src": {Added: []string{"c"}},
+			},
+		},
+		"strings and booleans": {
+			old: Directives{WebRTC: "allow"},
+			new: Directives{WebRTC: "block", UpgradeInsecureRequests: true},
+			want: map[string]SourceChange{
+				"webrtc":                    {Old: "'allow'", New: "'block'"},
+				"upgrade-insecure-requests": {Old: "false", New: "true"},
+			},
+		},
+		"empty sandbox": {
+			old: Directives{},
+			new: Directives{SandboxEmpty: true},
+			want: map[string]SourceChange{
+				"sandbox": {Old: "false", New: "true"},
+			},
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			if got := Diff(c.old, c.new); !reflect.DeepEqual(got, c.want) {
+				t.Fatalf(errorString, got, c.want)
+			}
+		})
+	}
+}
+
+func TestSourceChangeString(t *testing.T) {
+	cases := map[string]struct {
+		change SourceChange
+		want   string
+	}{
+		"sources": {
+			change: SourceChange{Added: []string{"https://cdn.new.com"}, Removed: []string{"https://cdn.old.com"}},
+			want:   "+https://cdn.new.com -https://cdn.old.com",
+		},
+		"values": {
+			change: SourceChange{Old: "'allow'", New: "'block'"},
+			want:   `"'allow'" -> "'block'"`,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			if got := c.change.String(); got != c.want {
+				t.Fatalf(errorString, got, c.want)
+			}
+		})
+	}
+}
+
+func TestIsSubset(t *testing.T) {
+	parent := Directives{
+		DefaultSrc: []string{"self", "https://*.example.com"},
+		ImgSrc:     []string{"*"},
+		ScriptSrc:  []string{"self", "https:"},
+		ObjectSrc:  []string{"none"},
+	}
+	cases := map[string]struct {
+		child Directives
+		want  bool
+	}{
+		"identical": {
+			child: parent,
+			want:  true,
+		},
+		"tightened": {
+			child: Directives{
+				DefaultSrc: []string{"self"},
+				ObjectSrc:  []string{"none"},
+			},
+			want: true,
+		},
+		"default-src fallback": {
+			child: Directives{
+				DefaultSrc: []string{"self"},
+				ObjectSrc:  []string{"none"},
+				FontSrc:    []string{"https://fonts.example.com"},
+			},
+			want: true,
+		},
+		"inherited default-src loosens fallback": {
+			child: Directives{
+				DefaultSrc: []string{"self", "https://other.com"},
+				ObjectSrc:  []string{"none"},
+				ScriptSrc:  []string{"self"},
+			},
+			want: false,
+		},
+		"missing directive": {
+			child: Directives{
+				DefaultSrc: []string{"self"},
+			},
+			want: false,
+		},
+		"wildcard covers host": {
+			child: Directives{
+				DefaultSrc: []string{"self"},
+				ImgSrc:     []string{"https://images.other.com", "https:"},
+				ObjectSrc:  []string{"none"},
+			},
+			want: true,
+		},
+		"wildcard excludes data": {
+			child: Directives{
+				DefaultSrc: []string{"self"},
+				ImgSrc:     []string{"data:"},
+				ObjectSrc:  []string{"none"},
+			},
+			want: false,
+		},
+		"scheme covers host": {
+			child: Directives{
+				DefaultSrc: []string{"self"},
+				ScriptSrc:  []string{"https://cdn.other.com"},
+				ObjectSrc:  []string{"none"},
+			},
+			want: true,
+		},
+		"keyword not covered": {
+			child: Directives{
+				DefaultSrc: []string{"self"},
+				ScriptSrc:  []string{"unsafe-inline"},
+				ObjectSrc:  []string{"none"},
+			},
+			want: false,
+		},
+		"loosened none": {
+			child: Directives{
+				DefaultSrc: []string{"self"},
+				ObjectSrc:  []string{"self"},
+			},
+			want: false,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			if got := IsSubset(c.child, parent); got != c.want {
+				t.Fatalf(errorString, got, c.want)
+			}
+		})
+	}
+}
+
+func TestIsSubsetUnrestrictedParent(t *testing.T) {
+	child := Directives{ScriptSrc: []string{"*", "unsafe-inline"}}
+	if !IsSubset(child, Directives{}) {
+		t.Fatalf(errorString, false, true)
+	}
+	if IsSubset(Directives{}, child) {
+		t.Fatalf(errorString, true, false)
+	}
+}
+
+func TestIsSubsetStarHost(t *testing.T) {
+	child := Directives{ScriptSrc: []string{"https://example.com"}, ImgSrc: []string{"example.com:443"}}
+	parent := Directives{ScriptSrc: []string{"https://*"}, ImgSrc: []string{"*:443"}}
+	if !IsSubset(child, parent) {
+		t.Fatalf(errorString, false, true)
+	}
+}
+
+func TestCovers(t *testing.T) {
+	cases := map[string]struct {
+		p, c string
+		want bool
+	}{
+		"equal":                    {"https://example.com", "https://example.com", true},
+		"star host":                {"*", "example.com", true},
+		"star self":                {"*", "'self'", true},
+		"star blob":                {"*", "blob:", false},
+		"star keyword":             {"*", "'unsafe-inline'", false},
+		"scheme host":              {"https:", "https://example.com", true},
+		"scheme other scheme":      {"https:", "http://example.com", false},
+		"scheme bare host":         {"https:", "example.com", false},
+		"wildcard subdomain":       {"*.example.com", "https://a.b.example.com", true},
+		"wildcard apex":            {"*.example.com", "example.com", false},
+		"wildcard with scheme":     {"https://*.example.com", "http://a.example.com", false},
+		"wildcard nested":          {"*.example.com", "*.a.example.com", true},
+		"host case":                {"https://Example.com", "https://example.COM", true},
+		"host different":           {"example.com", "example.org", false},
+		"path must match":          {"example.com/a", "example.com/b", false},
+		"no path in parent":        {"example.com", "example.com/b", true},
+		"host does not cover self": {"example.com", "'self'", false},
+		"scheme star host":         {"https://*", "https://example.com", true},
+		"scheme star other scheme": {"https://*", "http://example.com", false},
+		"star host with port":      {"*:443", "example.com:443", true},
+		"star host other port":     {"*:443", "example.com:8443", false},
+		"wildcard port":            {"*.example.com:*", "a.example.com:8080", true},
+		"port must match":          {"example.com:443", "example.com:8443", false},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			if got := covers(c.p, c.c); got != c.want {
+				t.Fatalf(errorString, got, c.want)
+			}
+		})
+	}
+}
+
+func TestIntersect(t *testing.T) {
+	cases := map[string]struct {
+		a, b Directives
+		want Directives
+	}{
+		"script-src": {
+			a:    Directives{ScriptSrc: []string{"'self'", "https://a.example.com", "https://b.example.com"}},
+			b:    Directives{ScriptSrc: []string{"https://b.example.com", "self"}},
+			want: Directives{ScriptSrc: []string{"'self'", "https://b.example.com"}},
+		},
+		"narrowed wildcard": {
+			a:    Directives{ScriptSrc: []string{"https:"}},
+			b:    Directives{ScriptSrc: []string{"https://x.example.com", "http://y.example.com"}},
+			want: Directives{ScriptSrc: []string{"https://x.example.com"}},
+		},
+		"scheme wildcard": {
+			a:    Directives{ScriptSrc: []string{"'self'", "https://cdn.example.com"}},
+			b:    Directives{ScriptSrc: []string{"https://*"}},
+			want: Directives{ScriptSrc: []string{"https://cdn.example.com"}},
+		},
+		"disjoint": {
+			a:    Directives{ScriptSrc: []string{"'self'"}},
+			b:    Directives{ScriptSrc: []string{"https://x.example.com"}},
+			want: Directives{ScriptSrc: []string{"'none'"}},
+		},
+		"one unrestricted": {
+			a:    Directives{ImgSrc: []string{"'self'"}},
+			want: Directives{ImgSrc: []string{"'self'"}},
+		},
+		"default-src fallback": {
+			a:    Directives{DefaultSrc: []string{"'self'"}},
+			b:    Directives{ScriptSrc: []string{"'self'", "https://x.example.com"}},
+			want: Directives{DefaultSrc: []string{"'self'"}},
+		},
+		"other directives": {
+			a:    Directives{Sandbox: "allow-forms allow-scripts", ReportTo: "a"},
+			b:    Directives{Sandbox: "allow-scripts", ReportTo: "b", WebRTC: WebRTCBlock, UpgradeInsecureRequests: true},
+			want: Directives{Sandbox: "allow-scripts", ReportTo: "a", WebRTC: WebRTCBlock, UpgradeInsecureRequests: true},
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := Intersect(c.a, c.b)
+			if !reflect.DeepEqual(got, c.want) {
+				t.Fatalf(errorString, got, c.want)
+			}
+		})
+	}
+}
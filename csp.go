@@ -2,65 +2,157 @@ package csp
 
 import (
 	"fmt"
+	"html"
 	"reflect"
 	"slices"
 	"strings"
+	"sync"
 )
 
 // HeaderKey is the canonical form of the Content Security Policy header key.
 const HeaderKey = "Content-Security-Policy"
 
+// ReportOnlyHeaderKey is the canonical form of the Content Security Policy
+// Report-Only header key. Policies sent under it are not enforced; violations
+// are only reported.
+const ReportOnlyHeaderKey = "Content-Security-Policy-Report-Only"
+
 // Acceptable webrtc values.
 const (
 	WebRTCAllow = "'allow'"
 	WebRTCBlock = "'block'"
 )
 
+// Acceptable sandbox tokens.
+const (
+	SandboxAllowDownloads                      = "allow-downloads"
+	SandboxAllowForms                          = "allow-forms"
+	SandboxAllowModals                         = "allow-modals"
+	SandboxAllowOrientationLock                = "allow-orientation-lock"
+	SandboxAllowPointerLock                    = "allow-pointer-lock"
+	SandboxAllowPopups                         = "allow-popups"
+	SandboxAllowPopupsToEscapeSandbox          = "allow-popups-to-escape-sandbox"
+	SandboxAllowPresentation                   = "allow-presentation"
+	SandboxAllowSameOrigin                     = "allow-same-origin"
+	SandboxAllowScripts                        = "allow-scripts"
+	SandboxAllowStorageAccessByUserActivation  = "allow-storage-access-by-user-activation"
+	SandboxAllowTopNavigation                  = "allow-top-navigation"
+	SandboxAllowTopNavigationByUserActivation  = "allow-top-navigation-by-user-activation"
+	SandboxAllowTopNavigationToCustomProtocols = "allow-top-navigation-to-custom-protocols"
+)
+
 // Acceptable keyword-sources used in directive values.
 const (
-	SourceNone                 = "'none'"
-	SourceSelf                 = "'self'"
-	SourceUnsafeInline         = "'unsafe-inline'"
-	SourceUnsafeEval           = "'unsafe-eval'"
-	SourceStrictDynamic        = "'strict-dynamic'"
-	SourceUnsafeHashes         = "'unsafe-hashes'"
-	SourceReportSample         = "'report-sample'"
-	SourceUnsafeAllowRedirects = "'unsafe-allow-redirects'"
-	SourceWasmUnsafeEval       = "'wasm-unsafe-eval'"
+	SourceNone                   = "'none'"
+	SourceSelf                   = "'self'"
+	SourceUnsafeInline           = "'unsafe-inline'"
+	SourceUnsafeEval             = "'unsafe-eval'"
+	SourceStrictDynamic          = "'strict-dynamic'"
+	SourceUnsafeHashes           = "'unsafe-hashes'"
+	SourceReportSample           = "'report-sample'"
+	SourceUnsafeAllowRedirects   = "'unsafe-allow-redirects'"
+	SourceWasmUnsafeEval         = "'wasm-unsafe-eval'"
+	SourceInlineSpeculationRules = "'inline-speculation-rules'"
+)
+
+// Acceptable keywords used in Trusted Types directive values.
+const (
+	TrustedTypesScript          = "'script'"
+	TrustedTypesAllowDuplicates = "'allow-duplicates'"
 )
 
 // CName is a mapping of the csp package's variable names to directive
 // names as outlined in Content Security Policy Level 3.
 var CName = map[string]string{
-	"BaseURI":        "base-uri",
-	"ChildSrc":       "child-src",
-	"ConnectSrc":     "connect-src",
-	"DefaultSrc":     "default-src",
-	"FontSrc":        "font-src",
-	"FormAction":     "form-action",
-	"FrameAncestors": "frame-ancestors",
-	"FrameSrc":       "frame-src",
-	"ImgSrc":         "img-src",
-	"ManifestSrc":    "manifest-src",
-	"MediaSrc":       "media-src",
-	"ObjectSrc":      "object-src",
-	"ReportTo":       "report-to",
-	"Sandbox":        "sandbox",
-	"ScriptSrc":      "script-src",
-	"ScriptSrcAttr":  "script-src-attr",
-	"ScriptSrcElem":  "script-src-elem",
-	"StyleSrc":       "style-src",
-	"StyleSrcAttr":   "style-src-attr",
-	"StyleSrcElem":   "style-src-elem",
-	"WebRTC":         "webrtc",
-	"WorkerSrc":      "worker-src",
+	"BaseURI":                 "base-uri",
+	"BlockAllMixedContent":    "block-all-mixed-content",
+	"ChildSrc":                "child-src",
+	"ConnectSrc":              "connect-src",
+	"DefaultSrc":              "default-src",
+	"FencedFrameSrc":          "fenced-frame-src",
+	"FontSrc":                 "font-src",
+	"FormAction":              "form-action",
+	"FrameAncestors":          "frame-ancestors",
+	"FrameSrc":                "frame-src",
+	"ImgSrc":                  "img-src",
+	"ManifestSrc":             "manifest-src",
+	"MediaSrc":                "media-src",
+	"NavigateTo":              "navigate-to",
+	"ObjectSrc":               "object-src",
+	"PluginTypes":             "plugin-types",
+	"PrefetchSrc":             "prefetch-src",
+	"ReportTo":                "report-to",
+	"ReportURI":               "report-uri",
+	"RequireTrustedTypesFor":  "require-trusted-types-for",
+	"Sandbox":                 "sandbox",
+	"ScriptSrc":               "script-src",
+	"ScriptSrcAttr":           "script-src-attr",
+	"ScriptSrcElem":           "script-src-elem",
+	"StyleSrc":                "style-src",
+	"StyleSrcAttr":            "style-src-attr",
+	"StyleSrcElem":            "style-src-elem",
+	"TrustedTypes":            "trusted-types",
+	"UpgradeInsecureRequests": "upgrade-insecure-requests",
+	"WebRTC":                  "webrtc",
+	"WorkerSrc":               "worker-src",
 }
 
-// IsKeywordSource returns true if s is a valid keyword-source as described in
-// Content Security Policy Level 3; they are required to be enclosed in
-// single-quotes.
-func IsKeywordSource(s string) bool {
-	sources := []string{
+// fieldName is the reverse of CName, mapping directive names to the csp
+// package's variable names.
+var fieldName = make(map[string]string, len(CName))
+
+// field describes a Directives field which models a directive.
+type field struct {
+	index int
+	name  string
+	dName string
+	kind  reflect.Kind
+	canon func(string) string
+}
+
+// fields describes every Directives field listed in CName in declaration
+// order. It is built once so that Policy need not look up field names, types,
+// and directive names on every call.
+var fields []field
+
+func init() {
+	for name, dName := range CName {
+		fieldName[dName] = name
+	}
+	typ := reflect.TypeOf(Directives{})
+	for i := 0; i < typ.NumField(); i++ {
+		sf := typ.Field(i)
+		dName, ok := CName[sf.Name]
+		if !ok {
+			continue
+		}
+		f := field{index: i, name: sf.Name, dName: dName, kind: sf.Type.Kind(), canon: canon}
+		if sf.Name == "TrustedTypes" {
+			f.canon = canonQuoted
+		}
+		fields = append(fields, f)
+	}
+}
+
+// IsDirective returns true if name is a directive name modelled by
+// Directives, such as "script-src".
+func IsDirective(name string) bool {
+	_, ok := fieldName[name]
+	return ok
+}
+
+// FieldName returns the name of the Directives field for the directive name
+// and true, or an empty string and false if the directive is unknown.
+func FieldName(directive string) (string, bool) {
+	name, ok := fieldName[directive]
+	return name, ok
+}
+
+// keywordSources are the keyword-sources recognized by IsKeywordSource,
+// including those added by RegisterKeywordSource.
+var (
+	keywordSourcesMu sync.RWMutex
+	keywordSources   = []string{
 		SourceNone,
 		SourceSelf,
 		SourceUnsafeInline,
@@ -70,22 +162,127 @@ func IsKeywordSource(s string) bool {
 		SourceReportSample,
 		SourceUnsafeAllowRedirects,
 		SourceWasmUnsafeEval,
+		SourceInlineSpeculationRules,
 		WebRTCAllow,
 		WebRTCBlock,
+		TrustedTypesScript,
+		TrustedTypesAllowDuplicates,
+	}
+)
+
+// IsKeywordSource returns true if s is a valid keyword-source as described in
+// Content Security Policy Level 3, or one added by RegisterKeywordSource;
+// they are required to be enclosed in single-quotes.
+func IsKeywordSource(s string) bool {
+	keywordSourcesMu.RLock()
+	defer keywordSourcesMu.RUnlock()
+	return slices.Contains(keywordSources, s)
+}
+
+// RegisterKeywordSource adds s, e.g. "'experimental-keyword'", to the
+// keyword-sources recognized by IsKeywordSource, so that it is canonicalized
+// like any other keyword. Keywords are case-insensitive and s is added
+// lowered. An error is returned if s is not enclosed in single-quotes or if
+// the keyword contains characters other than ASCII letters, digits, or "-".
+// It is safe to call concurrently with IsKeywordSource.
+func RegisterKeywordSource(s string) error {
+	inner, quoted := strings.CutPrefix(s, "'")
+	if inner, ok := strings.CutSuffix(inner, "'"); !quoted || !ok || !isDirectiveName(inner) {
+		return fmt.Errorf("csp: invalid keyword-source %q; it must be a single-quoted keyword", s)
 	}
-	return slices.Contains(sources, s)
+	kw := strings.ToLower(s)
+	keywordSourcesMu.Lock()
+	defer keywordSourcesMu.Unlock()
+	if !slices.Contains(keywordSources, kw) {
+		keywordSources = append(keywordSources, kw)
+	}
+	return nil
+}
+
+// ValidWebRTC returns true if s is a valid webrtc value, i.e. WebRTCAllow or
+// WebRTCBlock. Like other keywords, the value is case-insensitive and may be
+// given without single-quotes, e.g. "allow".
+func ValidWebRTC(s string) bool {
+	c := canon(s)
+	return c == WebRTCAllow || c == WebRTCBlock
+}
+
+// ValidSandboxToken returns true if s is a sandbox token recognized by the
+// HTML standard, such as "allow-scripts". Tokens are case-insensitive.
+func ValidSandboxToken(s string) bool {
+	tokens := []string{
+		SandboxAllowDownloads,
+		SandboxAllowForms,
+		SandboxAllowModals,
+		SandboxAllowOrientationLock,
+		SandboxAllowPointerLock,
+		SandboxAllowPopups,
+		SandboxAllowPopupsToEscapeSandbox,
+		SandboxAllowPresentation,
+		SandboxAllowSameOrigin,
+		SandboxAllowScripts,
+		SandboxAllowStorageAccessByUserActivation,
+		SandboxAllowTopNavigation,
+		SandboxAllowTopNavigationByUserActivation,
+		SandboxAllowTopNavigationToCustomProtocols,
+	}
+	return slices.Contains(tokens, strings.ToLower(s))
 }
 
 // canon returns s trimmed of leading and trailing white space. If s is a
-// keyword-source, it is also lowered and enclosed in single-quotes.
+// keyword-source, in any case and whether bare or enclosed in one or more
+// pairs of single-quotes with or without white space inside them, such as
+// "' Self '", it is also lowered and enclosed in a single pair of
+// single-quotes. If s is a URL-like host-source, its scheme and host are
+// lowered, and if s is a hash-source, its algorithm is lowered. Other quoted
+// values are left as they are; see Dequote.
 func canon(s string) string {
 	c := strings.TrimSpace(s)
-	if kw := "'" + strings.ToLower(c) + "'"; IsKeywordSource(kw) {
+	if kw := Quote(strings.ToLower(trimQuotes(c))); IsKeywordSource(kw) {
 		return kw
 	}
+	return lowerHashAlgo(lowerHost(c))
+}
+
+// trimQuotes returns s without leading and trailing white space and without
+// every pair of single-quotes enclosing it, along with the white space inside
+// them, e.g. "  ' 'Self' ' " becomes "Self".
+func trimQuotes(s string) string {
+	c := strings.TrimSpace(s)
+	for isQuoted(c) {
+		c = strings.TrimSpace(c[1 : len(c)-1])
+	}
 	return c
 }
 
+// lowerHashAlgo returns s with the algorithm of a hash-source s lowered, e.g.
+// "'SHA256-abc='" becomes "'sha256-abc='". The case-sensitive base64 digest
+// is left intact, as is any s which is not a hash-source.
+func lowerHashAlgo(s string) string {
+	algo, digest, ok := strings.Cut(s, "-")
+	if !ok || !strings.HasPrefix(algo, "'") {
+		return s
+	}
+	if c := strings.ToLower(algo) + "-" + digest; isHashSource(c) {
+		return c
+	}
+	return s
+}
+
+// lowerHost returns s with the scheme and host of a URL-like s lowered. The
+// case-sensitive path and query are left intact, as is any s without "://".
+func lowerHost(s string) string {
+	i := strings.Index(s, "://")
+	if i < 0 {
+		return s
+	}
+	end := len(s)
+	if j := strings.IndexAny(s[i+3:], "/?#"); j >= 0 {
+		end = i + 3 + j
+	}
+	return strings.ToLower(s[:end]) + s[end:]
+}
+
 // canons returns a slice of strings where every s in ss is trimmed of leading
 // and trailing white space. If s a keyword-source, it is also lowered and
 // enclosed in single-quotes.
@@ -97,6 +294,75 @@ func canons(ss []string) []string {
 	return cs
 }
 
+// canonQuoted returns s trimmed of leading and trailing white space. Unlike
+// canon, a bare s is never enclosed in single-quotes; s is only normalized
+// like canon if it is already a quoted keyword-source.
+func canonQuoted(s string) string {
+	c := strings.TrimSpace(s)
+	if !isQuoted(c) {
+		return c
+	}
+	if kw := Quote(strings.ToLower(trimQuotes(c))); IsKeywordSource(kw) {
+		return kw
+	}
+	return c
+}
+
+// policyNames returns a slice of strings where every s in ss is canonicalized
+// with canonQuoted, leaving case-sensitive Trusted Types policy names intact.
+func policyNames(ss []string) []string {
+	cs := make([]string, len(ss))
+	for i, s := range ss {
+		cs[i] = canonQuoted(s)
+	}
+	return cs
+}
+
+// canonSources returns ss canonicalized as appropriate for the Directives
+// field name.
+func canonSources(name string, ss []string) []string {
+	if name == "TrustedTypes" {
+		return policyNames(ss)
+	}
+	return canons(ss)
+}
+
+// dedup returns ss without duplicates, preserving the order in which each
+// string is first seen.
+func dedup(ss []string) []string {
+	ds := make([]string, 0, len(ss))
+	if len(ss) <= dedupScanMax {
+		for _, s := range ss {
+			if !slices.Contains(ds, s) {
+				ds = append(ds, s)
+			}
+		}
+		return ds
+	}
+	seen := make(map[string]bool, len(ss))
+	for _, s := range ss {
+		if !seen[s] {
+			seen[s] = true
+			ds = append(ds, s)
+		}
+	}
+	return ds
+}
+
+// dedupScanMax is the length up to which dedup scans for duplicates rather
+// than allocating a set; source lists are usually short.
+const dedupScanMax = 16
+
+// sortedKeys returns the keys of m in increasing order.
+func sortedKeys(m map[string][]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
 // Directives represent possible Content Security Policy rules that enable
 // developers to manage particular features of their websites.
 type Directives struct {
@@ -104,6 +370,12 @@ type Directives struct {
 	// can be used in a HTML <base> element.
 	BaseURI []string
 
+	// (block-all-mixed-content) BlockAllMixedContent is a deprecated directive
+	// that prevents loading any assets using HTTP when the page is loaded
+	// using HTTPS. It takes no value and is emitted only when true; prefer
+	// UpgradeInsecureRequests.
+	BlockAllMixedContent bool
+
 	// (child-src) ChildSrc is a fetch directive that restricts the sources for
 	// child navigables such as <frame> and <iframe> and Worker execution
 	// contexts.
@@ -119,6 +391,11 @@ type Directives struct {
 	// for other fetch directives.
 	DefaultSrc []string
 
+	// (fenced-frame-src) FencedFrameSrc is a fetch directive that restricts
+	// the URLs which may be loaded into <fencedframe> elements. It falls back
+	// to FrameSrc when not set.
+	FencedFrameSrc []string
+
 	// (font-src) FontSrc is a fetch directive that restricts the URLs from
 	// which font resources may be loaded.
 	FontSrc []string
@@ -149,19 +426,52 @@ type Directives struct {
 	// which video, audio, and associated text track resources may be loaded.
 	MediaSrc []string
 
+	// (navigate-to) NavigateTo is an experimental navigation directive that
+	// restricts the URLs to which a document can initiate navigations by any
+	// means. Support among user agents is limited.
+	NavigateTo []string
+
 	// (object-src) ObjectSrc is a fetch directive that restricts the URLs from
 	// which plugin content may be loaded.
 	ObjectSrc []string
 
+	// (plugin-types) PluginTypes is a deprecated document directive that
+	// restricts the MIME types, such as "application/pdf", of plugins which
+	// may be loaded. It has been removed from the specification; it is kept
+	// so that legacy policies may be read and preserved.
+	PluginTypes []string
+
+	// (prefetch-src) PrefetchSrc is a deprecated fetch directive that
+	// restricts the URLs which may be prefetched or prerendered. It has been
+	// removed from the specification and most user agents ignore it.
+	PrefetchSrc []string
+
 	// (report-to) ReportTo is a reporting directive that defines an endpoint to
 	// which violation reports should be sent.
 	ReportTo string
 
+	// (report-uri) ReportURI is a deprecated reporting directive that lists
+	// the URLs to which violation reports should be sent. It may be set
+	// alongside ReportTo for user agents that do not support report-to.
+	ReportURI []string
+
+	// (require-trusted-types-for) RequireTrustedTypesFor is a directive that
+	// instructs the user agent to control the data passed to DOM XSS sink
+	// functions - the only possible value is "'script'".
+	RequireTrustedTypesFor []string
+
 	// (sandbox) Sandbox is a navigation directive that specifies an HTML
 	// sandbox policy which the user agent will apply to a resource, as if it
-	// had been included in an <iframe> with a sandbox property.
+	// had been included in an <iframe> with a sandbox property. An empty
+	// Sandbox means the directive is not set; see SandboxEmpty.
 	Sandbox string
 
+	// SandboxEmpty emits a valueless sandbox directive, "sandbox;", when
+	// Sandbox is empty. A sandbox without tokens is the most restrictive
+	// sandbox, whereas an unset sandbox applies none at all. SandboxEmpty is
+	// ignored when Sandbox holds tokens.
+	SandboxEmpty bool
+
 	// (script-src) ScriptSrc is a fetch directive that restricts the locations
 	// from which scripts may be executed and serves as a default fallback for
 	// all script-like destinations.
@@ -188,6 +498,18 @@ type Directives struct {
 	// behaviour of styles except for styles defined in inline attributes.
 	StyleSrcElem []string
 
+	// (trusted-types) TrustedTypes is a directive that restricts the names of
+	// Trusted Types policies which may be created. Policy names are
+	// case-sensitive and are never quoted; only the "'none'" and
+	// "'allow-duplicates'" keywords are normalized.
+	TrustedTypes []string
+
+	// (upgrade-insecure-requests) UpgradeInsecureRequests is a directive that
+	// instructs the user agent to treat a site's insecure URLs as though they
+	// had been replaced with secure URLs. It takes no value and is emitted
+	// only when true.
+	UpgradeInsecureRequests bool
+
 	// (webrtc) WebRTC is a directive that restricts whether connections may be
 	// established via WebRTC - possible values are "'allow'" or "'block'".
 	WebRTC string
@@ -195,30 +517,185 @@ type Directives struct {
 	// (worker-src) WorkerSrc is a directive that restricts the URLs which may
 	// be loaded as a Worker, SharedWorker, or ServiceWorker.
 	WorkerSrc []string
+
+	// Extra holds directives which are not otherwise modelled by Directives,
+	// such as newer or experimental directives, keyed by directive name. Parse
+	// populates it with unrecognized directives and Policy emits them after
+	// all other directives, sorted by name. Extra values are emitted verbatim
+	// without canonicalization, and are part of the JSON form.
+	Extra map[string][]string
 }
 
 // Policy returns a white space joined string of all directives where each
-// directive ends in a semi-colon.
+// directive ends in a semi-colon. Duplicate sources within a directive are
+// removed after canonicalization, keeping the first occurrence.
 func Policy(ds Directives) string {
-	const dFormat = "%s %s; "
-	var policy strings.Builder
+	return join(entries(ds, PolicyOptions{}))
+}
+
+// PolicyOptions configures PolicyWith. The zero value gives the same output
+// as Policy.
+type PolicyOptions struct {
+	// SortSources emits the keyword, nonce, and hash sources of each
+	// directive before its other sources, such as host-sources and
+	// scheme-sources, keeping the order of the sources within each group.
+	SortSources bool
+}
+
+// PolicyWith returns the policy string of ds like Policy, configured by opts.
+func PolicyWith(ds Directives, opts PolicyOptions) string {
+	return join(entries(ds, opts))
+}
+
+// Size returns the length in bytes of the policy string returned by Policy.
+func Size(ds Directives) int {
+	return len(Policy(ds))
+}
+
+// PolicyOrdered returns the same directives as Policy, but emits the
+// directives named in order first, in the given order, followed by any
+// remaining directives in the default order. Names in order which are unknown
+// or not set are ignored.
+func PolicyOrdered(ds Directives, order []string) string {
+	es := entries(ds, PolicyOptions{})
+	ordered := make([]entry, 0, len(es))
+	for _, dName := range order {
+		if i := slices.IndexFunc(es, func(e entry) bool { return e.name == dName }); i >= 0 {
+			ordered = append(ordered, es[i])
+			es = slices.Delete(es, i, i+1)
+		}
+	}
+	return join(append(ordered, es...))
+}
+
+// PolicySorted returns the same directives as Policy, but emits them in
+// alphabetical order of their directive names, Extra directives included, so
+// the output does not depend on the order of the fields of Directives.
+func PolicySorted(ds Directives) string {
+	es := entries(ds, PolicyOptions{})
+	slices.SortFunc(es, func(a, b entry) int {
+		return strings.Compare(a.name, b.name)
+	})
+	return join(es)
+}
+
+// JoinPolicies returns policies joined into a single header value, separated
+// by ", ", as when several layers of an application each contribute a policy.
+// User agents enforce each policy independently, see ParseAll. Policies are
+// trimmed of leading and trailing white space, and empty policies are
+// skipped.
+func JoinPolicies(policies ...string) string {
+	var b strings.Builder
+	for _, p := range policies {
+		if p = strings.TrimSpace(p); p == "" {
+			continue
+		}
+		if b.Len() > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(p)
+	}
+	return b.String()
+}
+
+// bareSandbox returns true if ds emits a valueless sandbox directive, see
+// SandboxEmpty.
+func bareSandbox(ds Directives) bool {
+	return ds.SandboxEmpty && canon(ds.Sandbox) == ""
+}
+
+// entry is a serialized directive. The value of a valueless directive is
+// empty.
+type entry struct {
+	name  string
+	value string
+}
+
+// entries returns the serialized directives of ds in the default order,
+// configured by opts.
+func entries(ds Directives, opts PolicyOptions) []entry {
+	es := make([]entry, 0, len(fields)+len(ds.Extra))
 	val := reflect.ValueOf(&ds).Elem()
-	for i := 0; i < val.NumField(); i++ {
-		field := val.Field(i)
-		dName := CName[val.Type().Field(i).Name]
-		switch field.Kind() {
+	for _, f := range fields {
+		v := val.Field(f.index)
+		switch f.kind {
 		case reflect.Slice:
-			if slice := field.Interface().([]string); len(slice) > 0 {
-				dVal := strings.Join(canons(slice), " ")
-				policy.WriteString(fmt.Sprintf(dFormat, dName, dVal))
+			if n := v.Len(); n > 0 {
+				cs := make([]string, n)
+				for i := range cs {
+					cs[i] = f.canon(v.Index(i).String())
+				}
+				cs = dedup(cs)
+				if opts.SortSources {
+					slices.SortStableFunc(cs, func(a, b string) int {
+						return quotedRank(a) - quotedRank(b)
+					})
+				}
+				es = append(es, entry{f.dName, strings.Join(cs, " ")})
 			}
 		case reflect.String:
-			if dVal := canon(field.String()); dVal != "" {
-				policy.WriteString(fmt.Sprintf(dFormat, dName, dVal))
+			if dVal := f.canon(v.String()); dVal != "" {
+				es = append(es, entry{f.dName, dVal})
+			} else if f.name == "Sandbox" && ds.SandboxEmpty {
+				es = append(es, entry{f.dName, ""})
+			}
+		case reflect.Bool:
+			if v.Bool() {
+				es = append(es, entry{f.dName, ""})
 			}
 		}
 	}
-	return strings.TrimSpace(policy.String())
+	for _, dName := range sortedKeys(ds.Extra) {
+		es = append(es, entry{dName, strings.Join(ds.Extra[dName], " ")})
+	}
+	return es
+}
+
+// quotedRank returns 0 if s is enclosed in single-quotes, as keyword, nonce,
+// and hash sources are, and 1 otherwise.
+func quotedRank(s string) int {
+	if strings.HasPrefix(s, "'") {
+		return 0
+	}
+	return 1
+}
+
+// join returns a white space joined string of es where each directive ends
+// in a semi-colon.
+func join(es []entry) string {
+	if len(es) == 0 {
+		return ""
+	}
+	n := len(es) - 1
+	for _, e := range es {
+		n += len(e.name) + len(e.value) + 2
+	}
+	var policy strings.Builder
+	policy.Grow(n)
+	for i, e := range es {
+		if i > 0 {
+			policy.WriteByte(' ')
+		}
+		policy.WriteString(e.name)
+		if e.value != "" {
+			policy.WriteByte(' ')
+			policy.WriteString(e.value)
+		}
+		policy.WriteByte(';')
+	}
+	return policy.String()
+}
+
+// MetaTag returns ds as an HTML <meta> element which sets the policy for
+// documents that cannot be served with a header. User agents ignore the
+// frame-ancestors, report-uri, and sandbox directives when delivered this way,
+// so they are dropped from the policy.
+func MetaTag(ds Directives) string {
+	ds.FrameAncestors = nil
+	ds.ReportURI = nil
+	ds.Sandbox = ""
+	ds.SandboxEmpty = false
+	return `<meta http-equiv="` + HeaderKey + `" content="` + html.EscapeString(Policy(ds)) + `">`
 }
 
 // Basic returns a simple, non-strict CSP policy where sources is restricted to
@@ -256,3 +733,21 @@ func BasicTight() string {
 		StyleSrc:       self,
 	})
 }
+
+// Strict returns a strict CSP policy, as recommended by Google's CSP
+// Evaluator, which permits only scripts carrying the given per-request nonce
+// and the scripts they load. The following directives are set:
+//   - base-uri 'none'
+//   - object-src 'none'
+//   - script-src 'nonce-<nonce>' 'strict-dynamic' https: 'unsafe-inline'
+//
+// The https: and 'unsafe-inline' sources are fallbacks for older browsers
+// that do not support nonces or 'strict-dynamic'; they are ignored otherwise.
+func Strict(nonce string) string {
+	none := []string{SourceNone}
+	return Policy(Directives{
+		BaseURI:   none,
+		ObjectSrc: none,
+		ScriptSrc: []string{NonceSource(nonce), SourceStrictDynamic, "https:", SourceUnsafeInline},
+	})
+}
@@ -3,11 +3,17 @@ package csp
 import (
 	"fmt"
 	"reflect"
+	"slices"
+	"strings"
 	"testing"
 )
 
 const errorString = "\nGot:\t%v\nWant:\t%v\n"
 
+// directivesErrorString prints Directives field by field rather than as their
+// canonical policy string, which may hide differences.
+const directivesErrorString = "\nGot:\t%#v\nWant:\t%#v\n"
+
 func TestIsKeyWordSource(t *testing.T) {
 	cases := map[string]struct {
 		vals []string
@@ -22,7 +28,7 @@ func TestIsKeyWordSource(t *testing.T) {
 			want: false,
 		},
 		"keywords": {
-			vals: []string{"'none'", "'self'", "'unsafe-inline'"},
+			vals: []string{"'none'", "'self'", "'unsafe-inline'", SourceInlineSpeculationRules},
 			want: true,
 		},
 	}
@@ -37,6 +43,134 @@ func TestIsKeyWordSource(t *testing.T) {
 	}
 }
 
+func TestRegisterKeywordSource(t *testing.T) {
+	keywordSourcesMu.RLock()
+	original := slices.Clone(keywordSources)
+	keywordSourcesMu.RUnlock()
+	t.Cleanup(func() {
+		keywordSourcesMu.Lock()
+		keywordSources = original
+		keywordSourcesMu.Unlock()
+	})
+	if IsKeywordSource("'experimental-keyword'") {
+		t.Fatalf(errorString, true, false)
+	}
+	if err := RegisterKeywordSource("'Experimental-Keyword'"); err != nil {
+		t.Fatalf(errorString, err, nil)
+	}
+	if !IsKeywordSource("'experimental-keyword'") {
+		t.Fatalf(errorString, false, true)
+	}
+	want := "script-src 'self' 'experimental-keyword';"
+	if got := Policy(Directives{ScriptSrc: []string{"self", "EXPERIMENTAL-KEYWORD", "'experimental-keyword'"}}); got != want {
+		t.Fatalf(errorString, got, want)
+	}
+}
+
+func TestRegisterKeywordSourceErrors(t *testing.T) {
+	for _, s := range []string{"", "'", "''", "bare", "'unclosed", "unopened'", "'two words'", "'quo'te'"} {
+		t.Run(s, func(t *testing.T) {
+			if err := RegisterKeywordSource(s); err == nil {
+				t.Fatalf(errorString, err, "an error")
+			}
+		})
+	}
+}
+
+func TestIsDirectiveAndFieldName(t *testing.T) {
+	cases := map[string]struct {
+		directive string
+		name      string
+		ok        bool
+	}{
+		"valid": {
+			directive: "script-src-elem",
+			name:      "ScriptSrcElem",
+			ok:        true,
+		},
+		"valueless": {
+			directive: "upgrade-insecure-requests",
+			name:      "UpgradeInsecureRequests",
+			ok:        true,
+		},
+		"unknown": {
+			directive: "foo-src",
+		},
+		"field name": {
+			directive: "ScriptSrc",
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			if got := IsDirective(c.directive); got != c.ok {
+				t.Fatalf(errorString, got, c.ok)
+			}
+			got, ok := FieldName(c.directive)
+			if got != c.name || ok != c.ok {
+				t.Fatalf(errorString, []any{got, ok}, []any{c.name, c.ok})
+			}
+		})
+	}
+}
+
+func TestValidWebRTC(t *testing.T) {
+	cases := map[string]struct {
+		vals []string
+		want bool
+	}{
+		"quoted": {
+			vals: []string{WebRTCAllow, WebRTCBlock},
+			want: true,
+		},
+		"bare": {
+			vals: []string{"allow", "block", " allow "},
+			want: true,
+		},
+		"mixed case": {
+			vals: []string{"Allow", "BLOCK", "'Allow'"},
+			want: true,
+		},
+		"invalid": {
+			vals: []string{"", "maybe", "'deny'", "allow block", "self"},
+			want: false,
+		},
+	}
+	for name, c := range cases {
+		for i, v := range c.vals {
+			t.Run(fmt.Sprintf("%s %d", name, i), func(t *testing.T) {
+				if got := ValidWebRTC(v); got != c.want {
+					t.Fatalf(errorString, got, c.want)
+				}
+			})
+		}
+	}
+}
+
+func TestValidSandboxToken(t *testing.T) {
+	cases := map[string]struct {
+		vals []string
+		want bool
+	}{
+		"valid": {
+			vals: []string{SandboxAllowForms, SandboxAllowScripts, "Allow-Same-Origin"},
+			want: true,
+		},
+		"invalid": {
+			vals: []string{"", "allow-scrpits", "'allow-scripts'", "allow-forms allow-scripts"},
+			want: false,
+		},
+	}
+	for name, c := range cases {
+		for i, v := range c.vals {
+			t.Run(fmt.Sprintf("%s %d", name, i), func(t *testing.T) {
+				if got := ValidSandboxToken(v); got != c.want {
+					t.Fatalf(errorString, got, c.want)
+				}
+			})
+		}
+	}
+}
+
 func TestCanon(t *testing.T) {
 	cases := map[string]struct {
 		vals []string
@@ -50,6 +184,66 @@ func TestCanon(t *testing.T) {
 			vals: []string{"self", "    self   ", "'self'"},
 			want: "'self'",
 		},
+		"quoted keywords": {
+			vals: []string{"'SELF'", "'Self'", "' self '"},
+			want: "'self'",
+		},
+		"messy quoted keywords": {
+			vals: []string{"  'Self'  ", "\t'SELF'\n", "''self''", "' 'Self' '", "'  self\t'"},
+			want: "'self'",
+		},
+		"half quoted": {
+			vals: []string{"'self", " 'self "},
+			want: "'self",
+		},
+		"empty quotes": {
+			vals: []string{"''", " '' "},
+			want: "''",
+		},
+		"quoted non-keyword": {
+			vals: []string{"' https://example.com '"},
+			want: "' https://example.com '",
+		},
+		"report-sample": {
+			vals: []string{"report-sample", "'report-sample'"},
+			want: SourceReportSample,
+		},
+		"inline-speculation-rules": {
+			vals: []string{"inline-speculation-rules", "Inline-Speculation-Rules", "'inline-speculation-rules'"},
+			want: SourceInlineSpeculationRules,
+		},
+		"url host": {
+			vals: []string{"HTTPS://Example.COM", " https://example.com ", "https://EXAMPLE.com"},
+			want: "https://example.com",
+		},
+		"url path": {
+			vals: []string{"HTTPS://Example.COM:8443/Path/To", "https://example.com:8443/Path/To"},
+			want: "https://example.com:8443/Path/To",
+		},
+		"url query": {
+			vals: []string{"wss://Example.COM?Token=AbC"},
+			want: "wss://example.com?Token=AbC",
+		},
+		"bare host": {
+			vals: []string{"Example.COM"},
+			want: "Example.COM",
+		},
+		"nonce": {
+			vals: []string{"'nonce-AbC/dEf=='"},
+			want: "'nonce-AbC/dEf=='",
+		},
+		"hash": {
+			vals: []string{"'SHA384-XyZ=='", "'Sha384-XyZ=='", " 'sha384-XyZ==' "},
+			want: "'sha384-XyZ=='",
+		},
+		"unquoted hash": {
+			vals: []string{"SHA256-XyZ=="},
+			want: "SHA256-XyZ==",
+		},
+		"quoted non-hash": {
+			vals: []string{"'SHA1-XyZ=='"},
+			want: "'SHA1-XyZ=='",
+		},
 	}
 	for name, c := range cases {
 		for i, v := range c.vals {
@@ -85,6 +279,62 @@ func TestCanons(t *testing.T) {
 	}
 }
 
+func TestCanonQuoted(t *testing.T) {
+	cases := map[string]struct {
+		vals []string
+		want string
+	}{
+		"policy name": {
+			vals: []string{"myPolicy", "  myPolicy  "},
+			want: "myPolicy",
+		},
+		"bare keyword-like policy name": {
+			vals: []string{"allow-duplicates"},
+			want: "allow-duplicates",
+		},
+		"keywords": {
+			vals: []string{"'allow-duplicates'", "  'Allow-Duplicates' ", "' ALLOW-DUPLICATES '", "''allow-duplicates''"},
+			want: "'allow-duplicates'",
+		},
+	}
+	for name, c := range cases {
+		for i, v := range c.vals {
+			t.Run(fmt.Sprintf("%s %d", name, i), func(t *testing.T) {
+				if got := canonQuoted(v); got != c.want {
+					t.Fatalf(errorString, got, c.want)
+				}
+			})
+		}
+	}
+}
+
+func TestDedup(t *testing.T) {
+	cases := map[string]struct {
+		vals []string
+		want []string
+	}{
+		"empty": {
+			vals: []string{},
+			want: []string{},
+		},
+		"no duplicates": {
+			vals: []string{"'self'", "https://example.com"},
+			want: []string{"'self'", "https://example.com"},
+		},
+		"duplicates": {
+			vals: []string{"https://example.com", "'self'", "https://example.com", "'self'"},
+			want: []string{"https://example.com", "'self'"},
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			if got := dedup(c.vals); !reflect.DeepEqual(got, c.want) {
+				t.Fatalf(errorString, got, c.want)
+			}
+		})
+	}
+}
+
 func TestPolicy(t *testing.T) {
 	cases := map[string]struct {
 		directives Directives
@@ -104,6 +354,94 @@ func TestPolicy(t *testing.T) {
 			},
 			want: "default-src 'self'; report-to jd@example.com; style-src 'self' example.com;",
 		},
+		"upgrade insecure requests": {
+			directives: Directives{
+				DefaultSrc:              []string{"self"},
+				UpgradeInsecureRequests: true,
+			},
+			want: "default-src 'self'; upgrade-insecure-requests;",
+		},
+		"upgrade insecure requests false": {
+			directives: Directives{
+				DefaultSrc:              []string{"self"},
+				UpgradeInsecureRequests: false,
+			},
+			want: "default-src 'self';",
+		},
+		"block all mixed content": {
+			directives: Directives{
+				BlockAllMixedContent:    true,
+				DefaultSrc:              []string{"self"},
+				UpgradeInsecureRequests: true,
+			},
+			want: "block-all-mixed-content; default-src 'self'; upgrade-insecure-requests;",
+		},
+		"report uri": {
+			directives: Directives{
+				DefaultSrc: []string{"self"},
+				ReportURI:  []string{"https://example.com/CSP/Report", "/csp-reports"},
+			},
+			want: "default-src 'self'; report-uri https://example.com/CSP/Report /csp-reports;",
+		},
+		"report to and report uri": {
+			directives: Directives{
+				ReportTo:  "csp-endpoint",
+				ReportURI: []string{"https://example.com/CSP/Report"},
+			},
+			want: "report-to csp-endpoint; report-uri https://example.com/CSP/Report;",
+		},
+		"duplicate sources": {
+			directives: Directives{
+				ScriptSrc: []string{"'self'", "self", "SELF", " 'self' ", "https://example.com"},
+			},
+			want: "script-src 'self' https://example.com;",
+		},
+		"extra": {
+			directives: Directives{
+				DefaultSrc: []string{"self"},
+				Extra: map[string][]string{
+					"z-src":        {"Self"},
+					"foo-src":      {"https://example.com"},
+					"experimental": nil,
+				},
+			},
+			want: "default-src 'self'; experimental; foo-src https://example.com; z-src Self;",
+		},
+		"fenced frame src": {
+			directives: Directives{
+				FencedFrameSrc: []string{"https://ads.example.com"},
+				FontSrc:        []string{"self"},
+				FrameSrc:       []string{"self"},
+			},
+			want: "fenced-frame-src https://ads.example.com; font-src 'self'; frame-src 'self';",
+		},
+		"navigate to": {
+			directives: Directives{
+				NavigateTo: []string{"self", "unsafe-allow-redirects", "https://example.com"},
+			},
+			want: "navigate-to 'self' 'unsafe-allow-redirects' https://example.com;",
+		},
+		"plugin types": {
+			directives: Directives{
+				ObjectSrc:   []string{"self"},
+				PluginTypes: []string{" application/pdf ", "application/x-shockwave-flash"},
+			},
+			want: "object-src 'self'; plugin-types application/pdf application/x-shockwave-flash;",
+		},
+		"prefetch src": {
+			directives: Directives{
+				ObjectSrc:   []string{"none"},
+				PrefetchSrc: []string{"self", "https://cdn.example.com"},
+			},
+			want: "object-src 'none'; prefetch-src 'self' https://cdn.example.com;",
+		},
+		"trusted types": {
+			directives: Directives{
+				RequireTrustedTypesFor: []string{"script"},
+				TrustedTypes:           []string{"default", "DOMPurify", "'Allow-Duplicates'"},
+			},
+			want: "require-trusted-types-for 'script'; trusted-types default DOMPurify 'allow-duplicates';",
+		},
 	}
 	for name, c := range cases {
 		t.Run(name, func(t *testing.T) {
@@ -114,6 +452,270 @@ func TestPolicy(t *testing.T) {
 	}
 }
 
+func TestPolicyWith(t *testing.T) {
+	ds := Directives{
+		DefaultSrc: []string{"https://x.com", "self", "https:", "unsafe-inline", "https://a.com"},
+		ScriptSrc:  []string{"https://cdn.com", "'nonce-r4nd0m'", "strict-dynamic", "'sha256-abc='"},
+		Extra:      map[string][]string{"foo-src": {"https://x.com", "'self'"}},
+	}
+	cases := map[string]struct {
+		opts PolicyOptions
+		want string
+	}{
+		"default": {
+			want: Policy(ds),
+		},
+		"sort sources": {
+			opts: PolicyOptions{SortSources: true},
+			want: "default-src 'self' 'unsafe-inline' https://x.com https: https://a.com; " +
+				"script-src 'nonce-r4nd0m' 'strict-dynamic' 'sha256-abc=' https://cdn.com; " +
+				"foo-src https://x.com 'self';",
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			if got := PolicyWith(ds, c.opts); got != c.want {
+				t.Fatalf(errorString, got, c.want)
+			}
+		})
+	}
+}
+
+func TestPolicyValueless(t *testing.T) {
+	ds := Directives{
+		BlockAllMixedContent:    true,
+		DefaultSrc:              []string{"self"},
+		UpgradeInsecureRequests: true,
+		Extra:                   map[string][]string{"foo-directive": nil},
+	}
+	cases := map[string]struct {
+		got  string
+		want string
+	}{
+		"policy": {
+			got:  Policy(ds),
+			want: "block-all-mixed-content; default-src 'self'; upgrade-insecure-requests; foo-directive;",
+		},
+		"ordered": {
+			got:  PolicyOrdered(ds, []string{"upgrade-insecure-requests", "block-all-mixed-content"}),
+			want: "upgrade-insecure-requests; block-all-mixed-content; default-src 'self'; foo-directive;",
+		},
+		"sorted sources": {
+			got:  PolicyWith(ds, PolicyOptions{SortSources: true}),
+			want: "block-all-mixed-content; default-src 'self'; upgrade-insecure-requests; foo-directive;",
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			if c.got != c.want {
+				t.Fatalf(errorString, c.got, c.want)
+			}
+			if strings.Contains(c.got, " ;") || strings.Contains(c.got, "  ") {
+				t.Fatalf(errorString, c.got, "no stray white space")
+			}
+		})
+	}
+}
+
+func TestPolicySandboxEmpty(t *testing.T) {
+	cases := map[string]struct {
+		ds   Directives
+		want string
+	}{
+		"unset": {
+			ds:   Directives{DefaultSrc: []string{"self"}},
+			want: "default-src 'self';",
+		},
+		"empty": {
+			ds:   Directives{DefaultSrc: []string{"self"}, SandboxEmpty: true},
+			want: "default-src 'self'; sandbox;",
+		},
+		"tokens win": {
+			ds:   Directives{Sandbox: "allow-scripts", SandboxEmpty: true},
+			want: "sandbox allow-scripts;",
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := Policy(c.ds)
+			if got != c.want {
+				t.Fatalf(errorString, got, c.want)
+			}
+			parsed, err := Parse(got)
+			if err != nil {
+				t.Fatalf(errorString, err, nil)
+			}
+			if !Equal(parsed, c.ds) {
+				t.Fatalf(directivesErrorString, parsed, c.ds)
+			}
+		})
+	}
+}
+
+func TestJoinPolicies(t *testing.T) {
+	cases := map[string]struct {
+		policies []string
+		want     string
+	}{
+		"none": {},
+		"single": {
+			policies: []string{Basic()},
+			want:     Basic(),
+		},
+		"two": {
+			policies: []string{"default-src 'self';", "script-src 'none';"},
+			want:     "default-src 'self';, script-src 'none';",
+		},
+		"skips empty": {
+			policies: []string{"", " default-src 'self'; ", "  ", "script-src 'none';"},
+			want:     "default-src 'self';, script-src 'none';",
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			if got := JoinPolicies(c.policies...); got != c.want {
+				t.Fatalf(errorString, got, c.want)
+			}
+		})
+	}
+}
+
+func TestJoinPoliciesParseAll(t *testing.T) {
+	a := Directives{DefaultSrc: []string{"'self'"}}
+	b := Directives{ScriptSrc: []string{"'none'"}}
+	got, err := ParseAll(JoinPolicies(Policy(a), "", Policy(b)))
+	if err != nil {
+		t.Fatalf(errorString, err, nil)
+	}
+	if want := []Directives{a, b}; !reflect.DeepEqual(got, want) {
+		t.Fatalf(errorString, got, want)
+	}
+}
+
+func TestPolicyWebRTC(t *testing.T) {
+	cases := map[string]struct {
+		vals []string
+		want string
+	}{
+		"allow": {
+			vals: []string{"allow", "Allow", " ALLOW ", WebRTCAllow, "'allow'"},
+			want: "webrtc 'allow';",
+		},
+		"block": {
+			vals: []string{"block", "Block", "BLOCK", WebRTCBlock, "'BLOCK'"},
+			want: "webrtc 'block';",
+		},
+	}
+	for name, c := range cases {
+		for i, v := range c.vals {
+			t.Run(fmt.Sprintf("%s %d", name, i), func(t *testing.T) {
+				if got := Policy(Directives{WebRTC: v}); got != c.want {
+					t.Fatalf(errorString, got, c.want)
+				}
+			})
+		}
+	}
+}
+
+func TestSize(t *testing.T) {
+	cases := map[string]Directives{
+		"empty": {},
+		"full":  fullDirectives,
+		"duplicates": {
+			DefaultSrc: []string{"self", "'self'", " self "},
+		},
+		"extra": {
+			DefaultSrc: []string{"self"},
+			Extra:      map[string][]string{"foo-src": {"example.com"}},
+		},
+	}
+	for name, ds := range cases {
+		t.Run(name, func(t *testing.T) {
+			if got, want := Size(ds), len(Policy(ds)); got != want {
+				t.Fatalf(errorString, got, want)
+			}
+		})
+	}
+}
+
+func TestPolicyOrdered(t *testing.T) {
+	directives := Directives{
+		DefaultSrc: []string{"self"},
+		ImgSrc:     []string{"https:"},
+		ScriptSrc:  []string{"self"},
+		StyleSrc:   []string{"self"},
+	}
+	cases := map[string]struct {
+		order []string
+		want  string
+	}{
+		"no order": {
+			want: "default-src 'self'; img-src https:; script-src 'self'; style-src 'self';",
+		},
+		"default-src first": {
+			order: []string{"script-src", "default-src"},
+			want:  "script-src 'self'; default-src 'self'; img-src https:; style-src 'self';",
+		},
+		"unknown and unset ignored": {
+			order: []string{"style-src", "foo-src", "font-src", "style-src"},
+			want:  "style-src 'self'; default-src 'self'; img-src https:; script-src 'self';",
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			if got := PolicyOrdered(directives, c.order); got != c.want {
+				t.Fatalf(errorString, got, c.want)
+			}
+		})
+	}
+}
+
+func TestPolicySorted(t *testing.T) {
+	directives := Directives{
+		WorkerSrc:               []string{"self"},
+		UpgradeInsecureRequests: true,
+		Sandbox:                 "allow-scripts",
+		ReportTo:                "csp-endpoint",
+		DefaultSrc:              []string{"self"},
+		Extra:                   map[string][]string{"frame-src-x": {"'none'"}, "z-directive": nil},
+	}
+	want := "default-src 'self'; frame-src-x 'none'; report-to csp-endpoint; sandbox allow-scripts; upgrade-insecure-requests; worker-src 'self'; z-directive;"
+	if got := PolicySorted(directives); got != want {
+		t.Fatalf(errorString, got, want)
+	}
+}
+
+func TestMetaTag(t *testing.T) {
+	cases := map[string]struct {
+		directives Directives
+		want       string
+	}{
+		"escaped": {
+			directives: Directives{
+				DefaultSrc: []string{"self"},
+				ImgSrc:     []string{"https://example.com/?a=1&b=2"},
+			},
+			want: `<meta http-equiv="Content-Security-Policy" content="default-src &#39;self&#39;; img-src https://example.com/?a=1&amp;b=2;">`,
+		},
+		"unsupported directives dropped": {
+			directives: Directives{
+				DefaultSrc:     []string{"none"},
+				FrameAncestors: []string{"self"},
+				ReportURI:      []string{"/csp-reports"},
+				Sandbox:        "allow-scripts",
+			},
+			want: `<meta http-equiv="Content-Security-Policy" content="default-src &#39;none&#39;;">`,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			if got := MetaTag(c.directives); got != c.want {
+				t.Fatalf(errorString, got, c.want)
+			}
+		})
+	}
+}
+
 func TestBasicAndBasicTight(t *testing.T) {
 	cases := map[string]struct {
 		policy string
@@ -136,3 +738,143 @@ func TestBasicAndBasicTight(t *testing.T) {
 		})
 	}
 }
+
+func TestStrict(t *testing.T) {
+	got := Strict("r4nd0m")
+	want := "base-uri 'none'; object-src 'none'; script-src 'nonce-r4nd0m' 'strict-dynamic' https: 'unsafe-inline';"
+	if got != want {
+		t.Fatalf(errorString, got, want)
+	}
+}
+
+// fullDirectives sets every directive.
+var fullDirectives = Directives{
+	BaseURI:                 []string{"self"},
+	BlockAllMixedContent:    true,
+	ChildSrc:                []string{"self"},
+	ConnectSrc:              []string{"self", "https://api.example.com", "wss://ws.example.com"},
+	DefaultSrc:              []string{"none"},
+	FencedFrameSrc:          []string{"https://ads.example.com"},
+	FontSrc:                 []string{"self", "https://fonts.gstatic.com"},
+	FormAction:              []string{"self"},
+	FrameAncestors:          []string{"none"},
+	FrameSrc:                []string{"self", "https://www.youtube.com"},
+	ImgSrc:                  []string{"self", "data:", "https://images.example.com"},
+	ManifestSrc:             []string{"self"},
+	MediaSrc:                []string{"self", "https://media.example.com"},
+	NavigateTo:              []string{"self"},
+	ObjectSrc:               []string{"none"},
+	PluginTypes:             []string{"application/pdf"},
+	PrefetchSrc:             []string{"self"},
+	ReportTo:                "csp-endpoint",
+	ReportURI:               []string{"https://example.com/csp-reports"},
+	RequireTrustedTypesFor:  []string{"script"},
+	Sandbox:                 "allow-forms allow-scripts allow-same-origin",
+	ScriptSrc:               []string{"self", "'nonce-r4nd0m'", "strict-dynamic", "https://cdn.example.com"},
+	ScriptSrcAttr:           []string{"none"},
+	ScriptSrcElem:           []string{"self", "https://cdn.example.com"},
+	StyleSrc:                []string{"self", "unsafe-inline", "https://fonts.googleapis.com"},
+	StyleSrcAttr:            []string{"unsafe-inline"},
+	StyleSrcElem:            []string{"self", "https://fonts.googleapis.com"},
+	TrustedTypes:            []string{"default", "allow-duplicates"},
+	UpgradeInsecureRequests: true,
+	WebRTC:                  "block",
+	WorkerSrc:               []string{"self", "blob:"},
+	Extra:                   map[string][]string{"foo-src": {"'self'"}},
+}
+
+func BenchmarkPolicy(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		Policy(fullDirectives)
+	}
+}
+
+func BenchmarkPolicyLarge(b *testing.B) {
+	ds := fullDirectives.Clone()
+	for i := 0; i < 100; i++ {
+		ds.ConnectSrc = append(ds.ConnectSrc, fmt.Sprintf("https://api%d.example.com", i))
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		Policy(ds)
+	}
+}
+
+// policyReflect is the reference implementation of Policy, which looks up
+// every field's name, kind, and directive name by reflection on each call.
+func policyReflect(ds Directives) string {
+	const dFormat = "%s %s; "
+	var policy strings.Builder
+	val := reflect.ValueOf(&ds).Elem()
+	for i := 0; i < val.NumField(); i++ {
+		field := val.Field(i)
+		name := val.Type().Field(i).Name
+		dName := CName[name]
+		switch field.Kind() {
+		case reflect.Slice:
+			if slice := field.Interface().([]string); len(slice) > 0 {
+				dVal := strings.Join(dedup(canonSources(name, slice)), " ")
+				policy.WriteString(fmt.Sprintf(dFormat, dName, dVal))
+			}
+		case reflect.String:
+			if dVal := canon(field.String()); dVal != "" {
+				policy.WriteString(fmt.Sprintf(dFormat, dName, dVal))
+			} else if name == "Sandbox" && ds.SandboxEmpty {
+				policy.WriteString(dName + "; ")
+			}
+		case reflect.Bool:
+			if field.Bool() && dName != "" {
+				policy.WriteString(dName + "; ")
+			}
+		}
+	}
+	for _, dName := range sortedKeys(ds.Extra) {
+		if dVal := strings.Join(ds.Extra[dName], " "); dVal != "" {
+			policy.WriteString(fmt.Sprintf(dFormat, dName, dVal))
+		} else {
+			policy.WriteString(dName + "; ")
+		}
+	}
+	return strings.TrimSpace(policy.String())
+}
+
+// fuzzDirectives returns Directives where every source-list directive is set
+// to the fields of a or b in turn, every string directive to a, and every
+// valueless directive to flag.
+func fuzzDirectives(a, b string, flag bool) Directives {
+	var ds Directives
+	val := reflect.ValueOf(&ds).Elem()
+	for i := 0; i < val.NumField(); i++ {
+		switch field := val.Field(i); field.Kind() {
+		case reflect.Slice:
+			if i%2 == 0 {
+				field.Set(reflect.ValueOf(strings.Fields(a)))
+			} else {
+				field.Set(reflect.ValueOf(strings.Fields(b)))
+			}
+		case reflect.String:
+			field.SetString(a)
+		case reflect.Bool:
+			field.SetBool(flag)
+		}
+	}
+	if b != "" {
+		ds.Extra = map[string][]string{"foo-src": strings.Fields(b)}
+	}
+	return ds
+}
+
+func FuzzPolicy(f *testing.F) {
+	f.Add("", "", false)
+	f.Add("self", "'none'", true)
+	f.Add("self SELF 'self' https://example.com", "unsafe-inline 'nonce-r4nd0m' data:", true)
+	f.Add("  allow-duplicates   default ", "script", false)
+	f.Fuzz(func(t *testing.T, a, b string, flag bool) {
+		ds := fuzzDirectives(a, b, flag)
+		if got, want := Policy(ds), policyReflect(ds); got != want {
+			t.Fatalf(errorString, got, want)
+		}
+	})
+}
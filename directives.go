@@ -0,0 +1,177 @@
+package csp
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// String returns the policy string of ds, as returned by Policy.
+func (ds Directives) String() string {
+	return Policy(ds)
+}
+
+// Append adds sources to the directive named directiveName, e.g. "script-src".
+// Sources are appended to source-list directives, while string-valued
+// directives are set to the space joined sources and valueless directives
+// are set to true. Appending no sources to sandbox sets SandboxEmpty. An
+// error is returned if directiveName is unknown or if sources are given for a
+// valueless directive.
+func (ds *Directives) Append(directiveName string, sources ...string) error {
+	name, ok := fieldName[directiveName]
+	if !ok {
+		return fmt.Errorf("csp: unknown directive %q", directiveName)
+	}
+	field := reflect.ValueOf(ds).Elem().FieldByName(name)
+	switch field.Kind() {
+	case reflect.Slice:
+		field.Set(reflect.AppendSlice(field, reflect.ValueOf(sources)))
+	case reflect.String:
+		field.SetString(strings.Join(sources, " "))
+		if name == "Sandbox" && len(sources) == 0 {
+			ds.SandboxEmpty = true
+		}
+	case reflect.Bool:
+		if len(sources) > 0 {
+			return fmt.Errorf("csp: directive %q takes no value", directiveName)
+		}
+		field.SetBool(true)
+	}
+	return nil
+}
+
+// AddSandboxToken appends token, e.g. SandboxAllowScripts, to Sandbox unless
+// it is already present. Tokens are case-insensitive and are added lowered.
+// An error is returned if token is not a valid sandbox token, see
+// ValidSandboxToken.
+func (ds *Directives) AddSandboxToken(token string) error {
+	c := strings.ToLower(strings.TrimSpace(token))
+	if !ValidSandboxToken(c) {
+		return fmt.Errorf("csp: invalid sandbox token %q", token)
+	}
+	tokens := strings.Fields(ds.Sandbox)
+	if slices.ContainsFunc(tokens, func(t string) bool { return strings.EqualFold(t, c) }) {
+		return nil
+	}
+	ds.Sandbox = strings.Join(append(tokens, c), " ")
+	return nil
+}
+
+// Lock sets the source-list directive named directiveName, e.g. "object-src",
+// to 'none', replacing any sources, so that it explicitly allows nothing
+// rather than falling back to default-src. An error is returned if
+// directiveName is unknown or is not a source-list directive.
+func (ds *Directives) Lock(directiveName string) error {
+	name, ok := fieldName[directiveName]
+	if !ok {
+		return fmt.Errorf("csp: unknown directive %q", directiveName)
+	}
+	field := reflect.ValueOf(ds).Elem().FieldByName(name)
+	if field.Kind() != reflect.Slice {
+		return fmt.Errorf("csp: directive %q is not a source list", directiveName)
+	}
+	field.Set(reflect.ValueOf([]string{SourceNone}))
+	return nil
+}
+
+// RemoveSource removes every occurrence of source, after canonicalization,
+// from every source-list directive of ds. A directive emptied by the removal
+// is left unset rather than locked, see Lock. Extra is not modified.
+func (ds *Directives) RemoveSource(source string) {
+	val := reflect.ValueOf(ds).Elem()
+	for _, f := range fields {
+		v := val.Field(f.index)
+		if f.kind != reflect.Slice || v.Len() == 0 {
+			continue
+		}
+		c := f.canon(source)
+		kept := slices.DeleteFunc(slices.Clone(v.Interface().([]string)), func(s string) bool {
+			return f.canon(s) == c
+		})
+		switch len(kept) {
+		case v.Len():
+			continue
+		case 0:
+			kept = nil
+		}
+		v.Set(reflect.ValueOf(kept))
+	}
+}
+
+// Normalize canonicalizes ds in place so that equivalent policies have
+// byte-identical Policy strings. Every source is canonicalized as by Policy
+// and duplicates are removed. The sources of each directive are then sorted
+// into keyword-sources, nonce-sources, hash-sources, scheme-sources, and all
+// other sources such as host-sources, in that order, each group sorted
+// lexically. String fields are canonicalized, and Extra is left verbatim.
+func (ds *Directives) Normalize() {
+	val := reflect.ValueOf(ds).Elem()
+	for _, f := range fields {
+		v := val.Field(f.index)
+		switch f.kind {
+		case reflect.Slice:
+			if v.Len() == 0 {
+				continue
+			}
+			sources := dedup(canonSources(f.name, v.Interface().([]string)))
+			slices.SortFunc(sources, func(a, b string) int {
+				if ra, rb := sourceRank(a), sourceRank(b); ra != rb {
+					return ra - rb
+				}
+				return strings.Compare(a, b)
+			})
+			v.Set(reflect.ValueOf(sources))
+		case reflect.String:
+			v.SetString(f.canon(v.String()))
+		}
+	}
+}
+
+// sourceRank returns the position of the group of the canonicalized source s
+// in the order used by Normalize.
+func sourceRank(s string) int {
+	switch {
+	case IsKeywordSource(s):
+		return 0
+	case isNonceSource(s):
+		return 1
+	case isHashSource(s):
+		return 2
+	case isSchemeSource(s):
+		return 3
+	}
+	return 4
+}
+
+// Clone returns a deep copy of ds. Every slice field and Extra are freshly
+// allocated, so the clone may be modified without affecting ds.
+func (ds Directives) Clone() Directives {
+	val := reflect.ValueOf(&ds).Elem()
+	for i := 0; i < val.NumField(); i++ {
+		if field := val.Field(i); field.Kind() == reflect.Slice && !field.IsNil() {
+			field.Set(reflect.ValueOf(slices.Clone(field.Interface().([]string))))
+		}
+	}
+	if ds.Extra != nil {
+		extra := make(map[string][]string, len(ds.Extra))
+		for dName, sources := range ds.Extra {
+			extra[dName] = slices.Clone(sources)
+		}
+		ds.Extra = extra
+	}
+	return ds
+}
+
+// SetAllFetch sets every fetch directive, i.e. default-src and the directives
+// which fall back to it, to sources. Other directives, such as base-uri,
+// form-action, frame-ancestors, and sandbox, are left untouched. Each
+// directive receives its own copy of sources.
+func (ds *Directives) SetAllFetch(sources ...string) {
+	val := reflect.ValueOf(ds).Elem()
+	for _, f := range fields {
+		if _, ok := fallbacks[f.dName]; ok || f.dName == "default-src" {
+			val.Field(f.index).Set(reflect.ValueOf(slices.Clone(sources)))
+		}
+	}
+}
@@ -0,0 +1,290 @@
+package csp
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestString(t *testing.T) {
+	ds := Directives{
+		DefaultSrc: []string{"self"},
+		ImgSrc:     []string{"https:"},
+	}
+	want := "default-src 'self'; img-src https:;"
+	for name, got := range map[string]string{
+		"String":  ds.String(),
+		"Sprint":  fmt.Sprint(ds),
+		"Sprintf": fmt.Sprintf("%v", ds),
+	} {
+		t.Run(name, func(t *testing.T) {
+			if got != want {
+				t.Fatalf(errorString, got, want)
+			}
+		})
+	}
+}
+
+func TestAppend(t *testing.T) {
+	var got Directives
+	config := []struct {
+		directive string
+		sources   []string
+	}{
+		{"default-src", []string{"'self'"}},
+		{"script-src", []string{"'self'"}},
+		{"script-src", []string{"https://a.example.com", "https://b.example.com"}},
+		{"report-to", []string{"csp-endpoint"}},
+		{"sandbox", []string{"allow-forms", "allow-scripts"}},
+		{"upgrade-insecure-requests", nil},
+	}
+	for _, c := range config {
+		if err := got.Append(c.directive, c.sources...); err != nil {
+			t.Fatalf(errorString, err, nil)
+		}
+	}
+	want := Directives{
+		DefaultSrc:              []string{"'self'"},
+		ScriptSrc:               []string{"'self'", "https://a.example.com", "https://b.example.com"},
+		ReportTo:                "csp-endpoint",
+		Sandbox:                 "allow-forms allow-scripts",
+		UpgradeInsecureRequests: true,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf(directivesErrorString, got, want)
+	}
+}
+
+func TestAppendErrors(t *testing.T) {
+	cases := map[string]struct {
+		directive string
+		sources   []string
+	}{
+		"unknown directive": {
+			directive: "ScriptSrc",
+			sources:   []string{"'self'"},
+		},
+		"valueless with sources": {
+			directive: "upgrade-insecure-requests",
+			sources:   []string{"'self'"},
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			var ds Directives
+			if err := ds.Append(c.directive, c.sources...); err == nil {
+				t.Fatalf(errorString, err, "error")
+			}
+			if !reflect.DeepEqual(ds, Directives{}) {
+				t.Fatalf(directivesErrorString, ds, Directives{})
+			}
+		})
+	}
+}
+
+func TestAddSandboxToken(t *testing.T) {
+	ds := Directives{Sandbox: "allow-forms"}
+	for _, token := range []string{SandboxAllowScripts, "Allow-Popups", "allow-forms", "ALLOW-SCRIPTS"} {
+		if err := ds.AddSandboxToken(token); err != nil {
+			t.Fatalf(errorString, err, nil)
+		}
+	}
+	want := "allow-forms allow-scripts allow-popups"
+	if ds.Sandbox != want {
+		t.Fatalf(errorString, ds.Sandbox, want)
+	}
+	if err := ds.AddSandboxToken("allow-scrpits"); err == nil {
+		t.Fatalf(errorString, err, "an error")
+	}
+	if ds.Sandbox != want {
+		t.Fatalf(errorString, ds.Sandbox, want)
+	}
+}
+
+func TestAddSandboxTokenEmpty(t *testing.T) {
+	var ds Directives
+	if err := ds.AddSandboxToken(SandboxAllowSameOrigin); err != nil {
+		t.Fatalf(errorString, err, nil)
+	}
+	if want := SandboxAllowSameOrigin; ds.Sandbox != want {
+		t.Fatalf(errorString, ds.Sandbox, want)
+	}
+}
+
+func TestLock(t *testing.T) {
+	ds := Directives{
+		DefaultSrc: []string{"'self'"},
+		ScriptSrc:  []string{"'self'", "https://example.com"},
+	}
+	for _, dName := range []string{"object-src", "script-src"} {
+		if err := ds.Lock(dName); err != nil {
+			t.Fatalf(errorString, err, nil)
+		}
+	}
+	want := "default-src 'self'; object-src 'none'; script-src 'none';"
+	if got := Policy(ds); got != want {
+		t.Fatalf(errorString, got, want)
+	}
+}
+
+func TestLockErrors(t *testing.T) {
+	cases := map[string]string{
+		"unknown directive": "ObjectSrc",
+		"string directive":  "sandbox",
+		"valueless":         "upgrade-insecure-requests",
+	}
+	for name, directive := range cases {
+		t.Run(name, func(t *testing.T) {
+			var ds Directives
+			if err := ds.Lock(directive); err == nil {
+				t.Fatalf(errorString, err, "error")
+			}
+			if !reflect.DeepEqual(ds, Directives{}) {
+				t.Fatalf(directivesErrorString, ds, Directives{})
+			}
+		})
+	}
+}
+
+func TestRemoveSource(t *testing.T) {
+	shared := []string{"'self'", "https://old-cdn.com"}
+	ds := Directives{
+		DefaultSrc: []string{"'self'"},
+		ImgSrc:     []string{"https://old-cdn.com"},
+		ScriptSrc:  shared,
+		StyleSrc:   []string{"'self'", "HTTPS://Old-CDN.com", "https://new-cdn.com"},
+		FormAction: shared,
+	}
+	ds.RemoveSource("https://old-cdn.com")
+	want := Directives{
+		DefaultSrc: []string{"'self'"},
+		ScriptSrc:  []string{"'self'"},
+		StyleSrc:   []string{"'self'", "https://new-cdn.com"},
+		FormAction: []string{"'self'"},
+	}
+	if !reflect.DeepEqual(ds, want) {
+		t.Fatalf(directivesErrorString, ds, want)
+	}
+	if want := []string{"'self'", "https://old-cdn.com"}; !reflect.DeepEqual(shared, want) {
+		t.Fatalf(errorString, shared, want)
+	}
+}
+
+func TestRemoveSourceKeyword(t *testing.T) {
+	ds := Directives{ScriptSrc: []string{"'self'", "unsafe-inline"}}
+	ds.RemoveSource("'unsafe-inline'")
+	want := Directives{ScriptSrc: []string{"'self'"}}
+	if !reflect.DeepEqual(ds, want) {
+		t.Fatalf(directivesErrorString, ds, want)
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	ds := Directives{
+		DefaultSrc:   []string{"https://b.example.com", " self ", "HTTPS://A.example.com/Path", "'self'", "https:", "none"},
+		ScriptSrc:    []string{"https://cdn.example.com", "'sha256-abc='", "'nonce-r4nd0m'", "strict-dynamic", "unsafe-inline", "data:"},
+		TrustedTypes: []string{"myPolicy", "'allow-duplicates'", "default"},
+		ReportTo:     " csp-endpoint ",
+		WebRTC:       "Block",
+		Extra:        map[string][]string{"foo-src": {"b", "a"}},
+	}
+	ds.Normalize()
+	want := Directives{
+		DefaultSrc:   []string{"'none'", "'self'", "https:", "https://a.example.com/Path", "https://b.example.com"},
+		ScriptSrc:    []string{"'strict-dynamic'", "'unsafe-inline'", "'nonce-r4nd0m'", "'sha256-abc='", "data:", "https://cdn.example.com"},
+		TrustedTypes: []string{"'allow-duplicates'", "default", "myPolicy"},
+		ReportTo:     "csp-endpoint",
+		WebRTC:       "'block'",
+		Extra:        map[string][]string{"foo-src": {"b", "a"}},
+	}
+	if !reflect.DeepEqual(ds, want) {
+		t.Fatalf(directivesErrorString, ds, want)
+	}
+}
+
+func TestNormalizeEquivalent(t *testing.T) {
+	a := Directives{ImgSrc: []string{"https://b.com", "self", "data:"}}
+	b := Directives{ImgSrc: []string{"data:", "'self'", "https://b.com", "https://B.com"}}
+	a.Normalize()
+	b.Normalize()
+	if Policy(a) != Policy(b) {
+		t.Fatalf(errorString, Policy(a), Policy(b))
+	}
+}
+
+func TestClone(t *testing.T) {
+	base := Directives{
+		DefaultSrc: make([]string, 1, 4),
+		ScriptSrc:  []string{"'self'"},
+		ReportTo:   "csp-endpoint",
+		Extra:      map[string][]string{"foo-src": {"'self'"}},
+	}
+	base.DefaultSrc[0] = "'self'"
+	want := Directives{
+		DefaultSrc: []string{"'self'"},
+		ScriptSrc:  []string{"'self'"},
+		ReportTo:   "csp-endpoint",
+		Extra:      map[string][]string{"foo-src": {"'self'"}},
+	}
+	clone := base.Clone()
+	if !reflect.DeepEqual(clone, want) {
+		t.Fatalf(directivesErrorString, clone, want)
+	}
+	clone.ScriptSrc[0] = "https://example.com"
+	clone.DefaultSrc = append(clone.DefaultSrc, "https://example.com")
+	clone.Extra["foo-src"][0] = "https://example.com"
+	clone.Extra["bar-src"] = []string{"'none'"}
+	if !reflect.DeepEqual(base, want) {
+		t.Fatalf(directivesErrorString, base, want)
+	}
+	if got := base.DefaultSrc[:2]; got[1] != "" {
+		t.Fatalf(errorString, got, []string{"'self'", ""})
+	}
+}
+
+func TestSetAllFetch(t *testing.T) {
+	ds := Directives{
+		BaseURI:        []string{"'self'"},
+		FormAction:     []string{"'self'"},
+		FrameAncestors: []string{"'none'"},
+		ImgSrc:         []string{"*"},
+		ReportTo:       "csp",
+		Sandbox:        "allow-scripts",
+		WebRTC:         "'block'",
+	}
+	ds.SetAllFetch("'self'")
+	self := []string{"'self'"}
+	want := Directives{
+		BaseURI:        []string{"'self'"},
+		ChildSrc:       self,
+		ConnectSrc:     self,
+		DefaultSrc:     self,
+		FencedFrameSrc: self,
+		FontSrc:        self,
+		FormAction:     []string{"'self'"},
+		FrameAncestors: []string{"'none'"},
+		FrameSrc:       self,
+		ImgSrc:         self,
+		ManifestSrc:    self,
+		MediaSrc:       self,
+		ObjectSrc:      self,
+		PrefetchSrc:    self,
+		ReportTo:       "csp",
+		Sandbox:        "allow-scripts",
+		ScriptSrc:      self,
+		ScriptSrcAttr:  self,
+		ScriptSrcElem:  self,
+		StyleSrc:       self,
+		StyleSrcAttr:   self,
+		StyleSrcElem:   self,
+		WebRTC:         "'block'",
+		WorkerSrc:      self,
+	}
+	if !reflect.DeepEqual(ds, want) {
+		t.Fatalf(directivesErrorString, ds, want)
+	}
+	ds.ScriptSrc[0] = "'none'"
+	if ds.StyleSrc[0] != "'self'" {
+		t.Fatalf(errorString, ds.StyleSrc[0], "'self'")
+	}
+}
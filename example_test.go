@@ -0,0 +1,35 @@
+package csp_test
+
+import (
+	"fmt"
+
+	"github.com/novrin/csp"
+)
+
+func Example_policy() {
+	policy := csp.Policy(csp.Directives{
+		DefaultSrc: []string{"self"},
+		ImgSrc:     []string{"self", "https://images.example.com"},
+		ScriptSrc:  []string{"self", "strict-dynamic"},
+		ObjectSrc:  []string{"none"},
+	})
+	fmt.Println(policy)
+	// Output:
+	// default-src 'self'; img-src 'self' https://images.example.com; object-src 'none'; script-src 'self' 'strict-dynamic';
+}
+
+func Example_basic() {
+	fmt.Println(csp.Basic())
+	fmt.Println(csp.BasicTight())
+	// Output:
+	// default-src 'self'; form-action 'self'; frame-ancestors 'self';
+	// connect-src 'self'; default-src 'none'; form-action 'self'; frame-ancestors 'self'; img-src 'self'; script-src 'self'; style-src 'self';
+}
+
+func Example_strict() {
+	// A nonce is generated per request, e.g. with csp.Nonce; a fixed one is
+	// used here for a deterministic output.
+	fmt.Println(csp.Strict("r4nd0m"))
+	// Output:
+	// base-uri 'none'; object-src 'none'; script-src 'nonce-r4nd0m' 'strict-dynamic' https: 'unsafe-inline';
+}
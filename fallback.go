@@ -0,0 +1,67 @@
+package csp
+
+import "reflect"
+
+// fallbacks maps each fetch directive to the directives, in order, whose
+// sources apply when it is not set, as defined by Content Security Policy
+// Level 3. Directives which are not listed do not fall back.
+var fallbacks = map[string][]string{
+	"child-src":        {"default-src"},
+	"connect-src":      {"default-src"},
+	"fenced-frame-src": {"frame-src", "child-src", "default-src"},
+	"font-src":         {"default-src"},
+	"frame-src":        {"child-src", "default-src"},
+	"img-src":          {"default-src"},
+	"manifest-src":     {"default-src"},
+	"media-src":        {"default-src"},
+	"object-src":       {"default-src"},
+	"prefetch-src":     {"default-src"},
+	"script-src":       {"default-src"},
+	"script-src-attr":  {"script-src", "default-src"},
+	"script-src-elem":  {"script-src", "default-src"},
+	"style-src":        {"default-src"},
+	"style-src-attr":   {"style-src", "default-src"},
+	"style-src-elem":   {"style-src", "default-src"},
+	"worker-src":       {"child-src", "script-src", "default-src"},
+}
+
+// Effective returns the canonicalized sources which apply to the directive
+// named directiveName in ds. These are its own sources if set; otherwise,
+// for fetch directives, those of the first directive set in its fallback
+// chain as defined by Content Security Policy Level 3, e.g. script-src-elem
+// falls back to script-src and then default-src, and worker-src to child-src,
+// script-src, and then default-src. Nil is returned if neither the directive
+// nor any of its fallbacks is set, in which case the directive is
+// unrestricted, and for directives which do not fall back, such as base-uri,
+// form-action, and frame-ancestors, when they are not set.
+func Effective(ds Directives, directiveName string) []string {
+	s, _ := effective(ds, directiveName)
+	return s
+}
+
+// sources returns the canonicalized sources of the source-list directive
+// dName in ds, or nil if it is not set or not a source-list directive.
+func sources(ds Directives, dName string) []string {
+	name, ok := fieldName[dName]
+	if !ok {
+		return nil
+	}
+	field := reflect.ValueOf(ds).FieldByName(name)
+	if field.Kind() != reflect.Slice || field.Len() == 0 {
+		return nil
+	}
+	return dedup(canonSources(name, field.Interface().([]string)))
+}
+
+// effective returns the canonicalized sources which apply to the directive
+// dName in ds and true, following fallbacks when dName is not set. It returns
+// nil and false if neither dName nor any of its fallbacks is set, in which
+// case the directive is unrestricted.
+func effective(ds Directives, dName string) ([]string, bool) {
+	for _, d := range append([]string{dName}, fallbacks[dName]...) {
+		if s := sources(ds, d); s != nil {
+			return s, true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,71 @@
+package csp
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEffective(t *testing.T) {
+	ds := Directives{
+		DefaultSrc: []string{"self", "'self'"},
+		ChildSrc:   []string{"https://frames.example.com"},
+		ScriptSrc:  []string{"self", "https://cdn.example.com"},
+		FormAction: []string{"self"},
+	}
+	cases := map[string]struct {
+		directive string
+		want      []string
+	}{
+		"own sources": {
+			directive: "script-src",
+			want:      []string{"'self'", "https://cdn.example.com"},
+		},
+		"default-src": {
+			directive: "default-src",
+			want:      []string{"'self'"},
+		},
+		"img-src falls back to default-src": {
+			directive: "img-src",
+			want:      []string{"'self'"},
+		},
+		"script-src-elem falls back to script-src": {
+			directive: "script-src-elem",
+			want:      []string{"'self'", "https://cdn.example.com"},
+		},
+		"worker-src falls back to child-src": {
+			directive: "worker-src",
+			want:      []string{"https://frames.example.com"},
+		},
+		"fenced-frame-src falls back to child-src": {
+			directive: "fenced-frame-src",
+			want:      []string{"https://frames.example.com"},
+		},
+		"non-fetch set": {
+			directive: "form-action",
+			want:      []string{"'self'"},
+		},
+		"non-fetch unset": {
+			directive: "base-uri",
+		},
+		"frame-ancestors unset": {
+			directive: "frame-ancestors",
+		},
+		"unknown": {
+			directive: "foo-src",
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			if got := Effective(ds, c.directive); !reflect.DeepEqual(got, c.want) {
+				t.Fatalf(errorString, got, c.want)
+			}
+		})
+	}
+}
+
+func TestEffectiveUnrestricted(t *testing.T) {
+	ds := Directives{ImgSrc: []string{"self"}}
+	if got := Effective(ds, "script-src"); got != nil {
+		t.Fatalf(errorString, got, nil)
+	}
+}
@@ -1,3 +1,5 @@
 module github.com/novrin/csp
 
 go 1.21.5
+
+require golang.org/x/net v0.35.0
@@ -0,0 +1,186 @@
+package csp
+
+import (
+	"context"
+	"net/http"
+	"slices"
+)
+
+// HandlerOption configures the middleware returned by Handler.
+type HandlerOption func(*handlerConfig)
+
+// handlerConfig holds the settings applied by HandlerOptions.
+type handlerConfig struct {
+	key        string
+	overwrite  bool
+	styleNonce bool
+}
+
+// Overwrite returns a HandlerOption that replaces a policy header already set
+// on the response instead of preserving it.
+func Overwrite() HandlerOption {
+	return func(c *handlerConfig) {
+		c.overwrite = true
+	}
+}
+
+// StyleNonce returns a HandlerOption that makes NonceHandler add the nonce to
+// StyleSrc as well as ScriptSrc.
+func StyleNonce() HandlerOption {
+	return func(c *handlerConfig) {
+		c.styleNonce = true
+	}
+}
+
+// reportOnly is a HandlerOption that sets the policy under
+// ReportOnlyHeaderKey.
+func reportOnly(c *handlerConfig) {
+	c.key = ReportOnlyHeaderKey
+}
+
+// newHandlerConfig returns a handlerConfig with every opt applied.
+func newHandlerConfig(opts []HandlerOption) handlerConfig {
+	c := handlerConfig{key: HeaderKey}
+	for _, opt := range opts {
+		opt(&c)
+	}
+	return c
+}
+
+// set sets policy on w under the configured key, preserving an existing
+// header unless configured to overwrite it. It returns false if an existing
+// header was preserved.
+func (c handlerConfig) set(w http.ResponseWriter, policy string) bool {
+	h := w.Header()
+	if !c.overwrite && h.Get(c.key) != "" {
+		return false
+	}
+	h.Set(c.key, policy)
+	return true
+}
+
+// Handler returns a middleware that sets policy under HeaderKey on every
+// response before calling the next handler, so downstream handlers may still
+// override it. A header already set by the caller is preserved unless the
+// Overwrite option is given.
+func Handler(policy string, opts ...HandlerOption) func(http.Handler) http.Handler {
+	c := newHandlerConfig(opts)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			c.set(w, policy)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// ReportOnlyHandler returns a middleware like Handler that sets policy under
+// ReportOnlyHeaderKey, allowing a policy to be tested by collecting violation
+// reports before it is enforced.
+func ReportOnlyHandler(policy string, opts ...HandlerOption) func(http.Handler) http.Handler {
+	return Handler(policy, append(slices.Clip(opts), reportOnly)...)
+}
+
+// SetHeaders sets the policy of enforce under HeaderKey and the policy of
+// reportOnly under ReportOnlyHeaderKey on w, which allows a stricter policy
+// to be tested while the current one is enforced. A header is not set if its
+// policy is empty.
+func SetHeaders(w http.ResponseWriter, enforce, reportOnly Directives) {
+	enforce.Apply(w)
+	reportOnly.ApplyReportOnly(w)
+}
+
+// Apply sets the policy of ds under HeaderKey on w, replacing any existing
+// value. The header is not set if the policy is empty.
+func (ds Directives) Apply(w http.ResponseWriter) {
+	setPolicy(w, HeaderKey, Policy(ds))
+}
+
+// ApplyReportOnly sets the policy of ds under ReportOnlyHeaderKey on w,
+// replacing any existing value. The header is not set if the policy is empty.
+func (ds Directives) ApplyReportOnly(w http.ResponseWriter) {
+	setPolicy(w, ReportOnlyHeaderKey, Policy(ds))
+}
+
+// setPolicy sets policy under key on w unless policy is empty.
+func setPolicy(w http.ResponseWriter, key, policy string) {
+	if policy != "" {
+		w.Header().Set(key, policy)
+	}
+}
+
+// FromHeader returns the Directives of the policy set under HeaderKey in h and
+// true, or false if there is none. If h holds several policies, either in
+// several header values or comma-separated in one, user agents enforce each
+// of them, allowing only what every policy allows; FromHeader parses the
+// first, use FromHeaderAll to parse them all.
+func FromHeader(h http.Header) (Directives, bool, error) {
+	dss, err := ParseAll(h.Get(HeaderKey))
+	switch {
+	case err != nil:
+		return Directives{}, true, err
+	case len(dss) == 0:
+		return Directives{}, false, nil
+	}
+	return dss[0], true, nil
+}
+
+// FromHeaderAll returns the Directives of every policy set under HeaderKey in
+// h, in order, or nil if there are none. Each header value may hold several
+// comma-separated policies, see ParseAll. User agents enforce every policy,
+// allowing only what all of them allow.
+func FromHeaderAll(h http.Header) ([]Directives, error) {
+	var dss []Directives
+	for _, v := range h.Values(HeaderKey) {
+		policies, err := ParseAll(v)
+		if err != nil {
+			return nil, err
+		}
+		dss = append(dss, policies...)
+	}
+	return dss, nil
+}
+
+// contextKey is the type of context keys defined by the csp package.
+type contextKey string
+
+// NonceKey is the request context key under which NonceHandler stores the raw
+// nonce of each request.
+const NonceKey contextKey = "csp-nonce"
+
+// NonceFromContext returns the raw nonce stored in ctx by NonceHandler, or an
+// empty string if there is none.
+func NonceFromContext(ctx context.Context) string {
+	nonce, _ := ctx.Value(NonceKey).(string)
+	return nonce
+}
+
+// NonceHandler returns a middleware like Handler that generates a fresh nonce
+// for every request. The nonce-source is added to ScriptSrc, and to StyleSrc
+// if the StyleNonce option is given, of a copy of base whose policy is then
+// set on the response; base itself is never modified. The raw nonce is stored
+// in the request context for templates to use, see NonceFromContext. If an
+// existing header is preserved, no nonce is stored, since the policy sent does
+// not contain it. A 500 Internal Server Error is returned if a nonce cannot be
+// generated.
+func NonceHandler(base Directives, opts ...HandlerOption) func(http.Handler) http.Handler {
+	c := newHandlerConfig(opts)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			nonce, err := Nonce()
+			if err != nil {
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				return
+			}
+			ds := base.Clone()
+			ds.ScriptSrc = append(ds.ScriptSrc, NonceSource(nonce))
+			if c.styleNonce {
+				ds.StyleSrc = append(ds.StyleSrc, NonceSource(nonce))
+			}
+			if !c.set(w, Policy(ds)) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), NonceKey, nonce)))
+		})
+	}
+}
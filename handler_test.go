@@ -0,0 +1,353 @@
+package csp
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+// okHandler is a terminal handler that writes a 200 status.
+var okHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+})
+
+func TestHandler(t *testing.T) {
+	cases := map[string]struct {
+		existing string
+		opts     []HandlerOption
+		want     string
+	}{
+		"sets header": {
+			want: Basic(),
+		},
+		"preserves existing header": {
+			existing: BasicTight(),
+			want:     BasicTight(),
+		},
+		"overwrites existing header": {
+			existing: BasicTight(),
+			opts:     []HandlerOption{Overwrite()},
+			want:     Basic(),
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			if c.existing != "" {
+				w.Header().Set(HeaderKey, c.existing)
+			}
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			Handler(Basic(), c.opts...)(okHandler).ServeHTTP(w, r)
+			if got := w.Header().Get(HeaderKey); got != c.want {
+				t.Fatalf(errorString, got, c.want)
+			}
+		})
+	}
+}
+
+func TestHandlerDownstreamOverride(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(HeaderKey, BasicTight())
+	})
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	Handler(Basic())(next).ServeHTTP(w, r)
+	if got, want := w.Header().Get(HeaderKey), BasicTight(); got != want {
+		t.Fatalf(errorString, got, want)
+	}
+}
+
+func TestReportOnlyHandler(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	ReportOnlyHandler(BasicTight())(okHandler).ServeHTTP(w, r)
+	if got, want := w.Header().Get(ReportOnlyHeaderKey), BasicTight(); got != want {
+		t.Fatalf(errorString, got, want)
+	}
+	if got := w.Header().Get(HeaderKey); got != "" {
+		t.Fatalf(errorString, got, "")
+	}
+}
+
+func TestReportOnlyHandlerOptsUnmodified(t *testing.T) {
+	opts := make([]HandlerOption, 1, 2)
+	opts[0] = Overwrite()
+	ReportOnlyHandler(BasicTight(), opts...)
+	if spare := opts[:2][1]; spare != nil {
+		t.Fatalf(errorString, "option written", nil)
+	}
+}
+
+func TestNonceHandler(t *testing.T) {
+	base := Directives{
+		DefaultSrc: []string{SourceSelf},
+		ScriptSrc:  []string{SourceStrictDynamic},
+		StyleSrc:   []string{SourceSelf},
+	}
+	original := base.Clone()
+	cases := map[string]struct {
+		opts []HandlerOption
+		want func(nonce string) string
+	}{
+		"script": {
+			want: func(nonce string) string {
+				return "default-src 'self'; script-src 'strict-dynamic' 'nonce-" + nonce + "'; style-src 'self';"
+			},
+		},
+		"script and style": {
+			opts: []HandlerOption{StyleNonce()},
+			want: func(nonce string) string {
+				return "default-src 'self'; script-src 'strict-dynamic' 'nonce-" + nonce + "'; style-src 'self' 'nonce-" + nonce + "';"
+			},
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			var nonce string
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				nonce = NonceFromContext(r.Context())
+			})
+			h := NonceHandler(base, c.opts...)(next)
+			seen := make(map[string]bool)
+			for i := 0; i < 3; i++ {
+				w := httptest.NewRecorder()
+				h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+				if nonce == "" || seen[nonce] {
+					t.Fatalf(errorString, nonce, "a fresh nonce")
+				}
+				seen[nonce] = true
+				if got, want := w.Header().Get(HeaderKey), c.want(nonce); got != want {
+					t.Fatalf(errorString, got, want)
+				}
+			}
+			if !reflect.DeepEqual(base, original) {
+				t.Fatalf(directivesErrorString, base, original)
+			}
+		})
+	}
+}
+
+func TestNonceHandlerPreservesHeader(t *testing.T) {
+	nonce := "unset"
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		nonce = NonceFromContext(r.Context())
+	})
+	w := httptest.NewRecorder()
+	w.Header().Set(HeaderKey, BasicTight())
+	NonceHandler(Directives{ScriptSrc: []string{SourceSelf}})(next).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if got, want := w.Header().Get(HeaderKey), BasicTight(); got != want {
+		t.Fatalf(errorString, got, want)
+	}
+	if nonce != "" {
+		t.Fatalf(errorString, nonce, "")
+	}
+}
+
+func TestNonceFromContextEmpty(t *testing.T) {
+	if got := NonceFromContext(context.Background()); got != "" {
+		t.Fatalf(errorString, got, "")
+	}
+}
+
+func TestSetHeaders(t *testing.T) {
+	basic := Directives{DefaultSrc: []string{"self"}}
+	tight := Directives{DefaultSrc: []string{"none"}, ScriptSrc: []string{"self"}}
+	cases := map[string]struct {
+		enforce, reportOnly Directives
+		want                http.Header
+	}{
+		"both": {
+			enforce:    basic,
+			reportOnly: tight,
+			want: http.Header{
+				HeaderKey:           {"default-src 'self';"},
+				ReportOnlyHeaderKey: {"default-src 'none'; script-src 'self';"},
+			},
+		},
+		"empty report-only": {
+			enforce: basic,
+			want: http.Header{
+				HeaderKey: {"default-src 'self';"},
+			},
+		},
+		"empty enforce": {
+			reportOnly: tight,
+			want: http.Header{
+				ReportOnlyHeaderKey: {"default-src 'none'; script-src 'self';"},
+			},
+		},
+		"neither": {
+			want: http.Header{},
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			SetHeaders(w, c.enforce, c.reportOnly)
+			if got := w.Header(); !reflect.DeepEqual(got, c.want) {
+				t.Fatalf(errorString, got, c.want)
+			}
+		})
+	}
+}
+
+func TestApply(t *testing.T) {
+	cases := map[string]struct {
+		ds         Directives
+		reportOnly bool
+		want       http.Header
+	}{
+		"enforce": {
+			ds: Directives{DefaultSrc: []string{"self"}},
+			want: http.Header{
+				HeaderKey: {"default-src 'self';"},
+			},
+		},
+		"report-only": {
+			ds:         Directives{DefaultSrc: []string{"self"}},
+			reportOnly: true,
+			want: http.Header{
+				ReportOnlyHeaderKey: {"default-src 'self';"},
+			},
+		},
+		"empty": {
+			want: http.Header{},
+		},
+		"empty report-only": {
+			reportOnly: true,
+			want:       http.Header{},
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			if c.reportOnly {
+				c.ds.ApplyReportOnly(w)
+			} else {
+				c.ds.Apply(w)
+			}
+			if got := w.Header(); !reflect.DeepEqual(got, c.want) {
+				t.Fatalf(errorString, got, c.want)
+			}
+		})
+	}
+}
+
+func TestApplyReplaces(t *testing.T) {
+	w := httptest.NewRecorder()
+	w.Header().Set(HeaderKey, "default-src *;")
+	Directives{DefaultSrc: []string{"self"}}.Apply(w)
+	want := []string{"default-src 'self';"}
+	if got := w.Header().Values(HeaderKey); !reflect.DeepEqual(got, want) {
+		t.Fatalf(errorString, got, want)
+	}
+}
+
+func TestFromHeader(t *testing.T) {
+	cases := map[string]struct {
+		values []string
+		want   Directives
+		ok     bool
+	}{
+		"absent": {},
+		"single": {
+			values: []string{"default-src 'self'; img-src *"},
+			want: Directives{
+				DefaultSrc: []string{"'self'"},
+				ImgSrc:     []string{"*"},
+			},
+			ok: true,
+		},
+		"multiple": {
+			values: []string{"default-src 'self'", "script-src 'none'"},
+			want: Directives{
+				DefaultSrc: []string{"'self'"},
+			},
+			ok: true,
+		},
+		"comma-separated": {
+			values: []string{"default-src 'self', script-src 'none'"},
+			want: Directives{
+				DefaultSrc: []string{"'self'"},
+			},
+			ok: true,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			h := http.Header{}
+			for _, v := range c.values {
+				h.Add(HeaderKey, v)
+			}
+			got, ok, err := FromHeader(h)
+			if err != nil {
+				t.Fatalf(errorString, err, nil)
+			}
+			if ok != c.ok {
+				t.Fatalf(errorString, ok, c.ok)
+			}
+			if !reflect.DeepEqual(got, c.want) {
+				t.Fatalf(directivesErrorString, got, c.want)
+			}
+		})
+	}
+}
+
+func TestFromHeaderError(t *testing.T) {
+	h := http.Header{HeaderKey: {"default-src 'self'; default-src *"}}
+	if _, ok, err := FromHeader(h); err == nil || !ok {
+		t.Fatalf(errorString, err, "an error")
+	}
+}
+
+func TestFromHeaderAll(t *testing.T) {
+	cases := map[string]struct {
+		values []string
+		want   []Directives
+	}{
+		"absent": {},
+		"single": {
+			values: []string{"default-src 'self'"},
+			want:   []Directives{{DefaultSrc: []string{"'self'"}}},
+		},
+		"multiple": {
+			values: []string{"default-src 'self'", "script-src 'none'"},
+			want: []Directives{
+				{DefaultSrc: []string{"'self'"}},
+				{ScriptSrc: []string{"'none'"}},
+			},
+		},
+		"comma-separated": {
+			values: []string{"default-src 'self', img-src *", "script-src 'none'"},
+			want: []Directives{
+				{DefaultSrc: []string{"'self'"}},
+				{ImgSrc: []string{"*"}},
+				{ScriptSrc: []string{"'none'"}},
+			},
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			h := http.Header{}
+			for _, v := range c.values {
+				h.Add(HeaderKey, v)
+			}
+			got, err := FromHeaderAll(h)
+			if err != nil {
+				t.Fatalf(errorString, err, nil)
+			}
+			if !reflect.DeepEqual(got, c.want) {
+				t.Fatalf(errorString, got, c.want)
+			}
+		})
+	}
+}
+
+func TestFromHeaderAllError(t *testing.T) {
+	h := http.Header{HeaderKey: {"default-src 'self'", "foo_src x"}}
+	if _, err := FromHeaderAll(h); err == nil {
+		t.Fatalf(errorString, err, "an error")
+	}
+}
@@ -0,0 +1,123 @@
+package csp
+
+import (
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// HashScripts returns the hash-sources, computed using algo, of every inline
+// <script> element in the HTML document, in document order and without
+// duplicates. The results are ready to be appended to ScriptSrc. Scripts with
+// a src attribute are external and scripts with a nonce attribute are already
+// permitted by their nonce, so both are skipped. An error is returned if the
+// document cannot be parsed or algo is unsupported, see HashSource.
+func HashScripts(document string, algo string) ([]string, error) {
+	doc, err := html.Parse(strings.NewReader(document))
+	if err != nil {
+		return nil, err
+	}
+	var hashes []string
+	for _, n := range elements(doc, atom.Script) {
+		if hasAttr(n, "src") || hasAttr(n, "nonce") {
+			continue
+		}
+		hash, err := HashSource(algo, text(n))
+		if err != nil {
+			return nil, err
+		}
+		hashes = append(hashes, hash)
+	}
+	return dedup(hashes), nil
+}
+
+// StyleHashes holds the hash-sources of the inline styles of an HTML
+// document, separated by the directive which governs them.
+type StyleHashes struct {
+	// Elem holds the hashes of <style> elements, governed by style-src-elem
+	// (or style-src).
+	Elem []string
+
+	// Attr holds the hashes of style attributes, governed by style-src-attr
+	// (or style-src). User agents only match hashes against style attributes
+	// when 'unsafe-hashes' is also present in the directive.
+	Attr []string
+}
+
+// HashStyles returns the hash-sources, computed using algo, of every inline
+// <style> element and style attribute in the HTML document, in document order
+// and without duplicates. <style> elements with a nonce attribute are already
+// permitted by their nonce and are skipped. An error is returned if the
+// document cannot be parsed or algo is unsupported, see HashSource.
+func HashStyles(document string, algo string) (StyleHashes, error) {
+	doc, err := html.Parse(strings.NewReader(document))
+	if err != nil {
+		return StyleHashes{}, err
+	}
+	var hashes StyleHashes
+	for _, n := range elements(doc, 0) {
+		if n.DataAtom == atom.Style && n.Namespace == "" && !hasAttr(n, "nonce") {
+			hash, err := HashSource(algo, text(n))
+			if err != nil {
+				return StyleHashes{}, err
+			}
+			hashes.Elem = append(hashes.Elem, hash)
+		}
+		if style, ok := attr(n, "style"); ok {
+			hash, err := HashSource(algo, style)
+			if err != nil {
+				return StyleHashes{}, err
+			}
+			hashes.Attr = append(hashes.Attr, hash)
+		}
+	}
+	if hashes.Elem != nil {
+		hashes.Elem = dedup(hashes.Elem)
+	}
+	if hashes.Attr != nil {
+		hashes.Attr = dedup(hashes.Attr)
+	}
+	return hashes, nil
+}
+
+// elements returns every HTML element node under n with the tag a, or every
+// element node if a is zero, in document order.
+func elements(n *html.Node, a atom.Atom) []*html.Node {
+	var ns []*html.Node
+	if n.Type == html.ElementNode && (a == 0 || n.DataAtom == a && n.Namespace == "") {
+		ns = append(ns, n)
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		ns = append(ns, elements(c, a)...)
+	}
+	return ns
+}
+
+// attr returns the value of the attribute of n named key and true, or an
+// empty string and false if n has no such attribute.
+func attr(n *html.Node, key string) (string, bool) {
+	for _, a := range n.Attr {
+		if a.Namespace == "" && a.Key == key {
+			return a.Val, true
+		}
+	}
+	return "", false
+}
+
+// hasAttr returns true if n has an attribute named key.
+func hasAttr(n *html.Node, key string) bool {
+	_, ok := attr(n, key)
+	return ok
+}
+
+// text returns the concatenated text of the child text nodes of n.
+func text(n *html.Node) string {
+	var b strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.TextNode {
+			b.WriteString(c.Data)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,80 @@
+package csp
+
+import (
+	"reflect"
+	"testing"
+)
+
+// page is an HTML document with inline, external, and nonced scripts.
+const page = `<!DOCTYPE html>
+<html>
+<head>
+<script>alert('Hello, world.');</script>
+<script src="https://cdn.example.com/app.js"></script>
+<script nonce="r4nd0m">alert('nonced');</script>
+<style>body { color: red; }</style>
+</head>
+<body>
+<p style="color: blue;">Hello</p>
+<style nonce="r4nd0m">p { color: green; }</style>
+<div style="color: blue;"><span style="margin: 0">!</span></div>
+<script>
+  alert('Hello, world.');
+</script>
+<script>alert('Hello, world.');</script>
+</body>
+</html>`
+
+func TestHashScripts(t *testing.T) {
+	got, err := HashScripts(page, HashSHA256)
+	if err != nil {
+		t.Fatalf(errorString, err, nil)
+	}
+	want := []string{
+		"'sha256-qznLcsROx4GACP2dm0UCKCzCG+HiZ1guq6ZZDob/Tng='",
+		"'sha256-haVyeupPm/LZ6OoOxXeAt6YukqVu0Vb0/RzugQLOdxo='",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf(errorString, got, want)
+	}
+}
+
+func TestHashScriptsUnsupported(t *testing.T) {
+	if _, err := HashScripts(page, "md5"); err == nil {
+		t.Fatalf(errorString, err, "unsupported hash algorithm error")
+	}
+}
+
+func TestHashStyles(t *testing.T) {
+	got, err := HashStyles(page, HashSHA256)
+	if err != nil {
+		t.Fatalf(errorString, err, nil)
+	}
+	want := StyleHashes{
+		Elem: []string{mustHash(t, "body { color: red; }")},
+		Attr: []string{mustHash(t, "color: blue;"), mustHash(t, "margin: 0")},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf(errorString, got, want)
+	}
+}
+
+func TestHashStylesNone(t *testing.T) {
+	got, err := HashStyles("<p>Hello</p>", HashSHA256)
+	if err != nil {
+		t.Fatalf(errorString, err, nil)
+	}
+	if want := (StyleHashes{}); !reflect.DeepEqual(got, want) {
+		t.Fatalf(errorString, got, want)
+	}
+}
+
+// mustHash returns the sha256 hash-source of content.
+func mustHash(t *testing.T, content string) string {
+	t.Helper()
+	hash, err := HashSource(HashSHA256, content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hash
+}
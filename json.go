@@ -0,0 +1,87 @@
+package csp
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// MarshalJSON returns ds as a JSON object keyed by directive name, e.g.
+// {"script-src": ["'self'"]}. Source-list directives are arrays, string
+// directives are strings, and valueless directives are booleans. Directives
+// that are not set are omitted, while an empty sandbox, see SandboxEmpty, is
+// an empty string. Extra directives are arrays under their names, unless they
+// share a name with a directive modelled by Directives.
+func (ds Directives) MarshalJSON() ([]byte, error) {
+	m := make(map[string]any)
+	val := reflect.ValueOf(ds)
+	for i := 0; i < val.NumField(); i++ {
+		field := val.Field(i)
+		dName, ok := CName[val.Type().Field(i).Name]
+		if !ok || field.IsZero() || (field.Kind() == reflect.Slice && field.Len() == 0) {
+			continue
+		}
+		m[dName] = field.Interface()
+	}
+	if bareSandbox(ds) {
+		m[CName["Sandbox"]] = ""
+	}
+	for dName, values := range ds.Extra {
+		if _, ok := fieldName[dName]; ok {
+			continue
+		}
+		if values == nil {
+			values = []string{}
+		}
+		m[dName] = values
+	}
+	return json.Marshal(m)
+}
+
+// UnmarshalJSON sets ds from a JSON object keyed by directive name, as
+// produced by MarshalJSON. An empty sandbox string sets SandboxEmpty. Keys
+// which are not modelled by Directives are added to Extra, as by Parse, and
+// must be arrays of strings. An error listing every malformed key is returned
+// if the object contains keys that are not lowercase directive names.
+func (ds *Directives) UnmarshalJSON(data []byte) error {
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal(data, &m); err != nil {
+		return err
+	}
+	var malformed []string
+	for dName := range m {
+		if !isDirectiveName(dName) || dName != strings.ToLower(dName) {
+			malformed = append(malformed, fmt.Sprintf("%q", dName))
+		}
+	}
+	if len(malformed) > 0 {
+		slices.Sort(malformed)
+		return fmt.Errorf("csp: malformed directive names %s", strings.Join(malformed, ", "))
+	}
+	var parsed Directives
+	val := reflect.ValueOf(&parsed).Elem()
+	for dName, raw := range m {
+		name, ok := fieldName[dName]
+		if !ok {
+			var values []string
+			if err := json.Unmarshal(raw, &values); err != nil {
+				return fmt.Errorf("csp: directive %q: %w", dName, err)
+			}
+			if parsed.Extra == nil {
+				parsed.Extra = make(map[string][]string)
+			}
+			parsed.Extra[dName] = values
+			continue
+		}
+		if err := json.Unmarshal(raw, val.FieldByName(name).Addr().Interface()); err != nil {
+			return fmt.Errorf("csp: directive %q: %w", dName, err)
+		}
+	}
+	if _, ok := m[CName["Sandbox"]]; ok && parsed.Sandbox == "" {
+		parsed.SandboxEmpty = true
+	}
+	*ds = parsed
+	return nil
+}
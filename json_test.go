@@ -0,0 +1,159 @@
+package csp
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMarshalJSON(t *testing.T) {
+	cases := map[string]struct {
+		directives Directives
+		want       string
+	}{
+		"empty": {
+			directives: Directives{},
+			want:       `{}`,
+		},
+		"extra": {
+			directives: Directives{
+				ScriptSrc: []string{"'self'"},
+				Extra:     map[string][]string{"foo-src": {"'self'"}, "bar": nil},
+			},
+			want: `{"bar":[],"foo-src":["'self'"],"script-src":["'self'"]}`,
+		},
+		"extra shadowed": {
+			directives: Directives{
+				ScriptSrc: []string{"'self'"},
+				Extra:     map[string][]string{"script-src": {"'none'"}},
+			},
+			want: `{"script-src":["'self'"]}`,
+		},
+		"mixed": {
+			directives: Directives{
+				DefaultSrc:              []string{"'self'"},
+				ScriptSrc:               []string{"'self'", "https://cdn.example.com"},
+				ReportTo:                "csp-endpoint",
+				UpgradeInsecureRequests: true,
+			},
+			want: `{"default-src":["'self'"],"report-to":"csp-endpoint","script-src":["'self'","https://cdn.example.com"],"upgrade-insecure-requests":true}`,
+		},
+		"empty sandbox": {
+			directives: Directives{SandboxEmpty: true},
+			want:       `{"sandbox":""}`,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := json.Marshal(c.directives)
+			if err != nil {
+				t.Fatalf(errorString, err, nil)
+			}
+			if string(got) != c.want {
+				t.Fatalf(errorString, string(got), c.want)
+			}
+		})
+	}
+}
+
+func TestUnmarshalJSON(t *testing.T) {
+	data := `{"default-src":["'self'"],"foo-src":["https://foo.example.com"],"report-to":"csp-endpoint","script-src":["'self'","https://cdn.example.com"],"upgrade-insecure-requests":true}`
+	want := Directives{
+		DefaultSrc:              []string{"'self'"},
+		ScriptSrc:               []string{"'self'", "https://cdn.example.com"},
+		ReportTo:                "csp-endpoint",
+		UpgradeInsecureRequests: true,
+		Extra:                   map[string][]string{"foo-src": {"https://foo.example.com"}},
+	}
+	var got Directives
+	if err := json.Unmarshal([]byte(data), &got); err != nil {
+		t.Fatalf(errorString, err, nil)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf(directivesErrorString, got, want)
+	}
+}
+
+func TestUnmarshalJSONErrors(t *testing.T) {
+	cases := map[string]struct {
+		data string
+		want string
+	}{
+		"malformed keys": {
+			data: `{"default-src":["'self'"],"script_src":["'self'"],"ScriptSrc":["'self'"]}`,
+			want: `malformed directive names "ScriptSrc", "script_src"`,
+		},
+		"extra wrong type": {
+			data: `{"foo-src":"'self'"}`,
+			want: `directive "foo-src"`,
+		},
+		"wrong type": {
+			data: `{"default-src":"'self'"}`,
+			want: `directive "default-src"`,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			var ds Directives
+			err := json.Unmarshal([]byte(c.data), &ds)
+			if err == nil || !strings.Contains(err.Error(), c.want) {
+				t.Fatalf(errorString, err, c.want)
+			}
+		})
+	}
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	want := Directives{
+		BaseURI:              []string{"'none'"},
+		BlockAllMixedContent: true,
+		Sandbox:              "allow-forms allow-scripts",
+		TrustedTypes:         []string{"default", "'allow-duplicates'"},
+		WebRTC:               WebRTCBlock,
+	}
+	data, err := json.Marshal(want)
+	if err != nil {
+		t.Fatalf(errorString, err, nil)
+	}
+	var got Directives
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf(errorString, err, nil)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf(directivesErrorString, got, want)
+	}
+}
+
+func TestJSONRoundTripExtra(t *testing.T) {
+	want, err := Parse("default-src 'self'; foo-src https://foo.example.com; bar")
+	if err != nil {
+		t.Fatalf(errorString, err, nil)
+	}
+	data, err := json.Marshal(want)
+	if err != nil {
+		t.Fatalf(errorString, err, nil)
+	}
+	var got Directives
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf(errorString, err, nil)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf(directivesErrorString, got, want)
+	}
+}
+
+func TestJSONRoundTripSandboxEmpty(t *testing.T) {
+	want := Directives{DefaultSrc: []string{"'self'"}, SandboxEmpty: true}
+	data, err := json.Marshal(want)
+	if err != nil {
+		t.Fatalf(errorString, err, nil)
+	}
+	var got Directives
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf(errorString, err, nil)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf(directivesErrorString, got, want)
+	}
+}
@@ -0,0 +1,72 @@
+package csp
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// FromMap returns the Directives described by m, keyed by directive name,
+// e.g. {"script-src": {"'self'"}}. Values are set as by Append: source-list
+// directives take the values as sources, string directives such as report-to
+// are set to the space joined values, and valueless directives are set to
+// true and must have no values. An error listing every unknown key is
+// returned if m contains keys that are not directive names.
+func FromMap(m map[string][]string) (Directives, error) {
+	if err := checkDirectiveNames(m); err != nil {
+		return Directives{}, err
+	}
+	var ds Directives
+	for _, dName := range sortedKeys(m) {
+		if err := ds.Append(dName, m[dName]...); err != nil {
+			return Directives{}, err
+		}
+	}
+	return ds, nil
+}
+
+// ToMap returns ds as a map keyed by directive name, the inverse of FromMap.
+// Source-list directives map to a copy of their sources, string directives to
+// a single-element slice, and valueless directives, including an empty
+// sandbox, to an empty slice. Directives that are not set are omitted, as is
+// Extra.
+func (ds Directives) ToMap() map[string][]string {
+	m := make(map[string][]string)
+	val := reflect.ValueOf(ds)
+	for _, f := range fields {
+		switch field := val.Field(f.index); field.Kind() {
+		case reflect.Slice:
+			if field.Len() > 0 {
+				m[f.dName] = slices.Clone(field.Interface().([]string))
+			}
+		case reflect.String:
+			if field.Len() > 0 {
+				m[f.dName] = []string{field.String()}
+			} else if f.name == "Sandbox" && ds.SandboxEmpty {
+				m[f.dName] = []string{}
+			}
+		case reflect.Bool:
+			if field.Bool() {
+				m[f.dName] = []string{}
+			}
+		}
+	}
+	return m
+}
+
+// checkDirectiveNames returns an error listing every key of m which is not a
+// directive name, or nil if there are none.
+func checkDirectiveNames[V any](m map[string]V) error {
+	var unknown []string
+	for dName := range m {
+		if _, ok := fieldName[dName]; !ok {
+			unknown = append(unknown, fmt.Sprintf("%q", dName))
+		}
+	}
+	if len(unknown) > 0 {
+		slices.Sort(unknown)
+		return fmt.Errorf("csp: unknown directives %s", strings.Join(unknown, ", "))
+	}
+	return nil
+}
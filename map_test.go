@@ -0,0 +1,139 @@
+package csp
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFromMap(t *testing.T) {
+	cases := map[string]struct {
+		m    map[string][]string
+		want Directives
+	}{
+		"empty": {},
+		"source lists": {
+			m: map[string][]string{
+				"default-src": {"'self'"},
+				"script-src":  {"'self'", "https://example.com"},
+			},
+			want: Directives{
+				DefaultSrc: []string{"'self'"},
+				ScriptSrc:  []string{"'self'", "https://example.com"},
+			},
+		},
+		"strings": {
+			m: map[string][]string{
+				"report-to": {"csp-endpoint"},
+				"sandbox":   {"allow-scripts", "allow-forms"},
+				"webrtc":    {"'block'"},
+			},
+			want: Directives{
+				ReportTo: "csp-endpoint",
+				Sandbox:  "allow-scripts allow-forms",
+				WebRTC:   "'block'",
+			},
+		},
+		"valueless": {
+			m: map[string][]string{
+				"upgrade-insecure-requests": {},
+				"block-all-mixed-content":   nil,
+			},
+			want: Directives{
+				BlockAllMixedContent:    true,
+				UpgradeInsecureRequests: true,
+			},
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := FromMap(c.m)
+			if err != nil {
+				t.Fatalf(errorString, err, nil)
+			}
+			if !reflect.DeepEqual(got, c.want) {
+				t.Fatalf(directivesErrorString, got, c.want)
+			}
+		})
+	}
+}
+
+func TestFromMapErrors(t *testing.T) {
+	cases := map[string]struct {
+		m    map[string][]string
+		want string
+	}{
+		"unknown": {
+			m: map[string][]string{
+				"script-src": {"'self'"},
+				"report-to":  {"csp-endpoint"},
+				"foo-src":    {"example.com"},
+				"bar-src":    {"example.com"},
+			},
+			want: `unknown directives "bar-src", "foo-src"`,
+		},
+		"valueless with value": {
+			m: map[string][]string{
+				"upgrade-insecure-requests": {"yes"},
+			},
+			want: `directive "upgrade-insecure-requests" takes no value`,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := FromMap(c.m)
+			if err == nil || !strings.Contains(err.Error(), c.want) {
+				t.Fatalf(errorString, err, c.want)
+			}
+		})
+	}
+}
+
+func TestFromMapCopies(t *testing.T) {
+	m := map[string][]string{"script-src": {"'self'"}}
+	ds, err := FromMap(m)
+	if err != nil {
+		t.Fatalf(errorString, err, nil)
+	}
+	m["script-src"][0] = "*"
+	if got, want := ds.ScriptSrc[0], "'self'"; got != want {
+		t.Fatalf(errorString, got, want)
+	}
+}
+
+func TestToMap(t *testing.T) {
+	ds := Directives{
+		DefaultSrc:              []string{"'self'"},
+		ImgSrc:                  []string{},
+		ReportTo:                "csp-endpoint",
+		Sandbox:                 "allow-scripts allow-forms",
+		UpgradeInsecureRequests: true,
+		Extra:                   map[string][]string{"foo-src": {"'self'"}},
+	}
+	want := map[string][]string{
+		"default-src":               {"'self'"},
+		"report-to":                 {"csp-endpoint"},
+		"sandbox":                   {"allow-scripts allow-forms"},
+		"upgrade-insecure-requests": {},
+	}
+	got := ds.ToMap()
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf(errorString, got, want)
+	}
+	got["default-src"][0] = "*"
+	if ds.DefaultSrc[0] != "'self'" {
+		t.Fatalf(errorString, ds.DefaultSrc[0], "'self'")
+	}
+}
+
+func TestToMapRoundTrip(t *testing.T) {
+	want := fullDirectives.Clone()
+	want.Extra = nil
+	got, err := FromMap(fullDirectives.ToMap())
+	if err != nil {
+		t.Fatalf(errorString, err, nil)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf(directivesErrorString, got, want)
+	}
+}
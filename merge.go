@@ -0,0 +1,115 @@
+package csp
+
+import (
+	"reflect"
+	"slices"
+)
+
+// Merge returns the union of base and override. Slice fields contain the
+// canonicalized sources of base followed by those of override, without
+// duplicates, and Extra directives are likewise combined verbatim. String
+// fields take the override value when it is non-empty and the base value
+// otherwise, and boolean fields are set if set in either.
+//
+// Because 'none' is exclusive, a merged directive where one side is 'none'
+// and the other has concrete sources is resolved in favour of the concrete
+// sources and 'none' is dropped. Use MergeConflicts to find such directives.
+func Merge(base, override Directives) Directives {
+	var ds Directives
+	bVal, oVal := reflect.ValueOf(base), reflect.ValueOf(override)
+	val := reflect.ValueOf(&ds).Elem()
+	for i := 0; i < val.NumField(); i++ {
+		field, bField, oField := val.Field(i), bVal.Field(i), oVal.Field(i)
+		switch field.Kind() {
+		case reflect.Slice:
+			name := val.Type().Field(i).Name
+			merged := mergeSources(name, bField.Interface().([]string), oField.Interface().([]string))
+			if len(merged) > 0 {
+				field.Set(reflect.ValueOf(merged))
+			}
+		case reflect.String:
+			if o := oField.String(); o != "" {
+				field.SetString(o)
+			} else {
+				field.SetString(bField.String())
+			}
+		case reflect.Bool:
+			field.SetBool(bField.Bool() || oField.Bool())
+		}
+	}
+	for dName, sources := range base.Extra {
+		mergeExtra(&ds, dName, sources)
+	}
+	for dName, sources := range override.Extra {
+		mergeExtra(&ds, dName, sources)
+	}
+	return ds
+}
+
+// mergeExtra adds sources to the Extra directive dName of ds, without
+// duplicates.
+func mergeExtra(ds *Directives, dName string, sources []string) {
+	if ds.Extra == nil {
+		ds.Extra = make(map[string][]string)
+	}
+	ds.Extra[dName] = dedup(append(slices.Clip(ds.Extra[dName]), sources...))
+}
+
+// MergeConflicts returns the names of directives where Merge resolves a
+// conflict between 'none' on one side and concrete sources on the other.
+func MergeConflicts(base, override Directives) []string {
+	var conflicts []string
+	bVal, oVal := reflect.ValueOf(base), reflect.ValueOf(override)
+	for i := 0; i < bVal.NumField(); i++ {
+		if bVal.Field(i).Kind() != reflect.Slice {
+			continue
+		}
+		name := bVal.Type().Field(i).Name
+		base, override := bVal.Field(i).Interface().([]string), oVal.Field(i).Interface().([]string)
+		merged := dedup(canonSources(name, append(slices.Clip(base), override...)))
+		if len(merged) > 1 && slices.Contains(merged, SourceNone) {
+			conflicts = append(conflicts, CName[name])
+		}
+	}
+	return conflicts
+}
+
+// mergeSources returns the canonicalized union of base and override for the
+// Directives field name, dropping 'none' if concrete sources are present.
+func mergeSources(name string, base, override []string) []string {
+	merged := dedup(canonSources(name, append(slices.Clip(base), override...)))
+	if len(merged) > 1 {
+		merged = slices.DeleteFunc(merged, func(s string) bool {
+			return s == SourceNone
+		})
+	}
+	return merged
+}
+
+// Override returns a copy of base where every directive in set is replaced by
+// its value in override, typically a partial policy and its DirectiveSet as
+// returned by ParseSet. Unlike Merge, a directive in set which is empty in
+// override clears the directive of base, and directives not in set are kept
+// from base even if set in override.
+func Override(base, override Directives, set DirectiveSet) Directives {
+	ds := base.Clone()
+	val, oVal := reflect.ValueOf(&ds).Elem(), reflect.ValueOf(override.Clone())
+	for _, f := range fields {
+		if set.Has(f.dName) {
+			val.Field(f.index).Set(oVal.Field(f.index))
+		}
+	}
+	if set.Has("sandbox") {
+		ds.SandboxEmpty = override.SandboxEmpty
+	}
+	for dName := range set {
+		if _, ok := fieldName[dName]; ok {
+			continue
+		}
+		if ds.Extra == nil {
+			ds.Extra = make(map[string][]string)
+		}
+		ds.Extra[dName] = slices.Clone(override.Extra[dName])
+	}
+	return ds
+}
@@ -0,0 +1,138 @@
+package csp
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMerge(t *testing.T) {
+	cases := map[string]struct {
+		base     Directives
+		override Directives
+		want     Directives
+	}{
+		"empty": {
+			want: Directives{},
+		},
+		"overlapping script-src": {
+			base: Directives{
+				ScriptSrc: []string{"self", "https://a.example.com"},
+			},
+			override: Directives{
+				ScriptSrc: []string{"https://b.example.com", "'self'"},
+			},
+			want: Directives{
+				ScriptSrc: []string{"'self'", "https://a.example.com", "https://b.example.com"},
+			},
+		},
+		"report-to override": {
+			base: Directives{
+				DefaultSrc: []string{"'self'"},
+				ReportTo:   "base-endpoint",
+			},
+			override: Directives{
+				ReportTo: "service-endpoint",
+			},
+			want: Directives{
+				DefaultSrc: []string{"'self'"},
+				ReportTo:   "service-endpoint",
+			},
+		},
+		"report-to kept": {
+			base: Directives{
+				ReportTo: "base-endpoint",
+			},
+			override: Directives{
+				UpgradeInsecureRequests: true,
+			},
+			want: Directives{
+				ReportTo:                "base-endpoint",
+				UpgradeInsecureRequests: true,
+			},
+		},
+		"extra": {
+			base: Directives{
+				Extra: map[string][]string{"foo-src": {"a", "b"}},
+			},
+			override: Directives{
+				Extra: map[string][]string{"foo-src": {"b", "c"}, "bar-src": {"d"}},
+			},
+			want: Directives{
+				Extra: map[string][]string{"foo-src": {"a", "b", "c"}, "bar-src": {"d"}},
+			},
+		},
+		"none conflict": {
+			base: Directives{
+				ObjectSrc: []string{"'none'"},
+				FrameSrc:  []string{"'none'"},
+			},
+			override: Directives{
+				ObjectSrc: []string{"https://example.com"},
+				FrameSrc:  []string{"none"},
+			},
+			want: Directives{
+				ObjectSrc: []string{"https://example.com"},
+				FrameSrc:  []string{"'none'"},
+			},
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			if got := Merge(c.base, c.override); !reflect.DeepEqual(got, c.want) {
+				t.Fatalf(directivesErrorString, got, c.want)
+			}
+		})
+	}
+}
+
+func TestMergeDoesNotMutate(t *testing.T) {
+	src := make([]string, 1, 4)
+	src[0] = "'self'"
+	base := Directives{ScriptSrc: src}
+	Merge(base, Directives{ScriptSrc: []string{"https://example.com"}})
+	if got, want := src[:2], []string{"'self'", ""}; !reflect.DeepEqual(got, want) {
+		t.Fatalf(errorString, got, want)
+	}
+}
+
+func TestMergeConflicts(t *testing.T) {
+	base := Directives{
+		ObjectSrc: []string{"'none'"},
+		ScriptSrc: []string{"'self'"},
+	}
+	override := Directives{
+		ObjectSrc: []string{"https://example.com"},
+		ScriptSrc: []string{"https://example.com"},
+	}
+	want := []string{"object-src"}
+	if got := MergeConflicts(base, override); !reflect.DeepEqual(got, want) {
+		t.Fatalf(errorString, got, want)
+	}
+}
+
+func TestOverride(t *testing.T) {
+	base := Directives{
+		DefaultSrc: []string{"'self'"},
+		ImgSrc:     []string{"'self'", "https://images.example.com"},
+		ScriptSrc:  []string{"'self'", "https://cdn.example.com"},
+		Sandbox:    "allow-scripts",
+		Extra:      map[string][]string{"foo-src": {"'self'"}},
+	}
+	override, set, err := ParseSet("script-src; img-src https://cdn.example.com; sandbox; bar-src 'none'")
+	if err != nil {
+		t.Fatalf(errorString, err, nil)
+	}
+	override.StyleSrc = []string{"'unsafe-inline'"}
+	want := Directives{
+		DefaultSrc:   []string{"'self'"},
+		ImgSrc:       []string{"https://cdn.example.com"},
+		SandboxEmpty: true,
+		Extra:        map[string][]string{"foo-src": {"'self'"}, "bar-src": {"'none'"}},
+	}
+	if got := Override(base, override, set); !reflect.DeepEqual(got, want) {
+		t.Fatalf(directivesErrorString, got, want)
+	}
+	if base.Sandbox != "allow-scripts" || len(base.Extra) != 1 {
+		t.Fatalf(directivesErrorString, base, "base unmodified")
+	}
+}
@@ -0,0 +1,251 @@
+package csp
+
+// Option modifies Directives, see New and With. Options for source-list
+// directives append sources, so several options for the same directive
+// accumulate; options for string-valued directives replace the value.
+type Option func(*Directives)
+
+// New returns the Directives assembled by applying opts in order, e.g.
+//
+//	csp.New(csp.WithDefaultSrc(csp.SourceSelf), csp.WithScriptSrc(csp.SourceSelf, nonce))
+func New(opts ...Option) Directives {
+	return Directives{}.With(opts...)
+}
+
+// With returns a copy of ds modified by applying opts in order. ds is not
+// modified.
+func (ds Directives) With(opts ...Option) Directives {
+	modified := ds.Clone()
+	for _, opt := range opts {
+		opt(&modified)
+	}
+	return modified
+}
+
+// WithBaseURI appends sources to the base-uri directive.
+func WithBaseURI(sources ...string) Option {
+	return func(ds *Directives) {
+		ds.BaseURI = append(ds.BaseURI, sources...)
+	}
+}
+
+// WithBlockAllMixedContent sets the valueless block-all-mixed-content
+// directive.
+func WithBlockAllMixedContent() Option {
+	return func(ds *Directives) {
+		ds.BlockAllMixedContent = true
+	}
+}
+
+// WithChildSrc appends sources to the child-src directive.
+func WithChildSrc(sources ...string) Option {
+	return func(ds *Directives) {
+		ds.ChildSrc = append(ds.ChildSrc, sources...)
+	}
+}
+
+// WithConnectSrc appends sources to the connect-src directive.
+func WithConnectSrc(sources ...string) Option {
+	return func(ds *Directives) {
+		ds.ConnectSrc = append(ds.ConnectSrc, sources...)
+	}
+}
+
+// WithDefaultSrc appends sources to the default-src directive.
+func WithDefaultSrc(sources ...string) Option {
+	return func(ds *Directives) {
+		ds.DefaultSrc = append(ds.DefaultSrc, sources...)
+	}
+}
+
+// WithFencedFrameSrc appends sources to the fenced-frame-src directive.
+func WithFencedFrameSrc(sources ...string) Option {
+	return func(ds *Directives) {
+		ds.FencedFrameSrc = append(ds.FencedFrameSrc, sources...)
+	}
+}
+
+// WithFontSrc appends sources to the font-src directive.
+func WithFontSrc(sources ...string) Option {
+	return func(ds *Directives) {
+		ds.FontSrc = append(ds.FontSrc, sources...)
+	}
+}
+
+// WithFormAction appends sources to the form-action directive.
+func WithFormAction(sources ...string) Option {
+	return func(ds *Directives) {
+		ds.FormAction = append(ds.FormAction, sources...)
+	}
+}
+
+// WithFrameAncestors appends sources to the frame-ancestors directive.
+func WithFrameAncestors(sources ...string) Option {
+	return func(ds *Directives) {
+		ds.FrameAncestors = append(ds.FrameAncestors, sources...)
+	}
+}
+
+// WithFrameSrc appends sources to the frame-src directive.
+func WithFrameSrc(sources ...string) Option {
+	return func(ds *Directives) {
+		ds.FrameSrc = append(ds.FrameSrc, sources...)
+	}
+}
+
+// WithImgSrc appends sources to the img-src directive.
+func WithImgSrc(sources ...string) Option {
+	return func(ds *Directives) {
+		ds.ImgSrc = append(ds.ImgSrc, sources...)
+	}
+}
+
+// WithManifestSrc appends sources to the manifest-src directive.
+func WithManifestSrc(sources ...string) Option {
+	return func(ds *Directives) {
+		ds.ManifestSrc = append(ds.ManifestSrc, sources...)
+	}
+}
+
+// WithMediaSrc appends sources to the media-src directive.
+func WithMediaSrc(sources ...string) Option {
+	return func(ds *Directives) {
+		ds.MediaSrc = append(ds.MediaSrc, sources...)
+	}
+}
+
+// WithNavigateTo appends sources to the navigate-to directive.
+func WithNavigateTo(sources ...string) Option {
+	return func(ds *Directives) {
+		ds.NavigateTo = append(ds.NavigateTo, sources...)
+	}
+}
+
+// WithObjectSrc appends sources to the object-src directive.
+func WithObjectSrc(sources ...string) Option {
+	return func(ds *Directives) {
+		ds.ObjectSrc = append(ds.ObjectSrc, sources...)
+	}
+}
+
+// WithPluginTypes appends MIME types to the plugin-types directive.
+func WithPluginTypes(types ...string) Option {
+	return func(ds *Directives) {
+		ds.PluginTypes = append(ds.PluginTypes, types...)
+	}
+}
+
+// WithPrefetchSrc appends sources to the prefetch-src directive.
+func WithPrefetchSrc(sources ...string) Option {
+	return func(ds *Directives) {
+		ds.PrefetchSrc = append(ds.PrefetchSrc, sources...)
+	}
+}
+
+// WithReportTo sets the value of the report-to directive.
+func WithReportTo(value string) Option {
+	return func(ds *Directives) {
+		ds.ReportTo = value
+	}
+}
+
+// WithReportURI appends sources to the report-uri directive.
+func WithReportURI(sources ...string) Option {
+	return func(ds *Directives) {
+		ds.ReportURI = append(ds.ReportURI, sources...)
+	}
+}
+
+// WithRequireTrustedTypesFor appends sources to the require-trusted-types-for
+// directive.
+func WithRequireTrustedTypesFor(sources ...string) Option {
+	return func(ds *Directives) {
+		ds.RequireTrustedTypesFor = append(ds.RequireTrustedTypesFor, sources...)
+	}
+}
+
+// WithSandbox sets the value of the sandbox directive.
+func WithSandbox(value string) Option {
+	return func(ds *Directives) {
+		ds.Sandbox = value
+	}
+}
+
+// WithSandboxEmpty sets the valueless sandbox directive, the most restrictive
+// sandbox, used when no sandbox value is set.
+func WithSandboxEmpty() Option {
+	return func(ds *Directives) {
+		ds.SandboxEmpty = true
+	}
+}
+
+// WithScriptSrc appends sources to the script-src directive.
+func WithScriptSrc(sources ...string) Option {
+	return func(ds *Directives) {
+		ds.ScriptSrc = append(ds.ScriptSrc, sources...)
+	}
+}
+
+// WithScriptSrcAttr appends sources to the script-src-attr directive.
+func WithScriptSrcAttr(sources ...string) Option {
+	return func(ds *Directives) {
+		ds.ScriptSrcAttr = append(ds.ScriptSrcAttr, sources...)
+	}
+}
+
+// WithScriptSrcElem appends sources to the script-src-elem directive.
+func WithScriptSrcElem(sources ...string) Option {
+	return func(ds *Directives) {
+		ds.ScriptSrcElem = append(ds.ScriptSrcElem, sources...)
+	}
+}
+
+// WithStyleSrc appends sources to the style-src directive.
+func WithStyleSrc(sources ...string) Option {
+	return func(ds *Directives) {
+		ds.StyleSrc = append(ds.StyleSrc, sources...)
+	}
+}
+
+// WithStyleSrcAttr appends sources to the style-src-attr directive.
+func WithStyleSrcAttr(sources ...string) Option {
+	return func(ds *Directives) {
+		ds.StyleSrcAttr = append(ds.StyleSrcAttr, sources...)
+	}
+}
+
+// WithStyleSrcElem appends sources to the style-src-elem directive.
+func WithStyleSrcElem(sources ...string) Option {
+	return func(ds *Directives) {
+		ds.StyleSrcElem = append(ds.StyleSrcElem, sources...)
+	}
+}
+
+// WithTrustedTypes appends sources to the trusted-types directive.
+func WithTrustedTypes(sources ...string) Option {
+	return func(ds *Directives) {
+		ds.TrustedTypes = append(ds.TrustedTypes, sources...)
+	}
+}
+
+// WithUpgradeInsecureRequests sets the valueless upgrade-insecure-requests
+// directive.
+func WithUpgradeInsecureRequests() Option {
+	return func(ds *Directives) {
+		ds.UpgradeInsecureRequests = true
+	}
+}
+
+// WithWebRTC sets the value of the webrtc directive.
+func WithWebRTC(value string) Option {
+	return func(ds *Directives) {
+		ds.WebRTC = value
+	}
+}
+
+// WithWorkerSrc appends sources to the worker-src directive.
+func WithWorkerSrc(sources ...string) Option {
+	return func(ds *Directives) {
+		ds.WorkerSrc = append(ds.WorkerSrc, sources...)
+	}
+}
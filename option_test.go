@@ -0,0 +1,60 @@
+package csp
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	cases := map[string]struct {
+		opts []Option
+		want Directives
+	}{
+		"empty": {
+			want: Directives{},
+		},
+		"accumulates": {
+			opts: []Option{
+				WithDefaultSrc(SourceSelf),
+				WithScriptSrc(SourceSelf),
+				WithScriptSrc("https://a.example.com", "https://b.example.com"),
+				WithUpgradeInsecureRequests(),
+			},
+			want: Directives{
+				DefaultSrc:              []string{SourceSelf},
+				ScriptSrc:               []string{SourceSelf, "https://a.example.com", "https://b.example.com"},
+				UpgradeInsecureRequests: true,
+			},
+		},
+		"replaces strings": {
+			opts: []Option{WithReportTo("a"), WithReportTo("b"), WithSandboxEmpty()},
+			want: Directives{
+				ReportTo:     "b",
+				SandboxEmpty: true,
+			},
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			if got := New(c.opts...); !reflect.DeepEqual(got, c.want) {
+				t.Fatalf(directivesErrorString, got, c.want)
+			}
+		})
+	}
+}
+
+func TestWith(t *testing.T) {
+	base := New(WithDefaultSrc(SourceSelf), WithScriptSrc(SourceSelf))
+	got := base.With(WithScriptSrc(SourceStrictDynamic), WithObjectSrc(SourceNone))
+	want := Directives{
+		DefaultSrc: []string{SourceSelf},
+		ObjectSrc:  []string{SourceNone},
+		ScriptSrc:  []string{SourceSelf, SourceStrictDynamic},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf(directivesErrorString, got, want)
+	}
+	if len(base.ScriptSrc) != 1 || len(base.ObjectSrc) != 0 {
+		t.Fatalf(directivesErrorString, base, "base unmodified")
+	}
+}
@@ -0,0 +1,138 @@
+package csp
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Parse returns the Directives described by header, a serialized Content
+// Security Policy such as the output of Policy. Directives are separated by
+// semi-colons and the first token of each directive is its name; the
+// remaining tokens are its value. Tokens are separated by any run of white
+// space, including tabs and newlines. Directive names are case-insensitive
+// and lowered, including those added to Extra, while values keep their case.
+// Valueless directives, such as upgrade-insecure-requests, set their field to
+// true, as does a bare sandbox for SandboxEmpty, and directives which are not
+// modelled by Directives are added to Extra. An error is returned if a
+// directive name is malformed or if a directive appears more than once.
+// header must hold a single policy; use ParseAll for comma-separated lists.
+func Parse(header string) (Directives, error) {
+	return ParseWith(header, ParseOptions{})
+}
+
+// ParseOptions configures ParseWith. The zero value parses like Parse.
+type ParseOptions struct {
+	// Lenient strips stray punctuation, i.e. trailing ":" or ",", from
+	// directive names, as left by copy-paste, so that "script-src:" is parsed
+	// as "script-src" rather than rejected as malformed.
+	Lenient bool
+}
+
+// ParseWith returns the Directives described by header like Parse,
+// configured by opts.
+func ParseWith(header string, opts ParseOptions) (Directives, error) {
+	ds, _, err := parse(header, opts)
+	return ds, err
+}
+
+// ParseAll returns the Directives of every policy in header, a serialized
+// policy list where policies are separated by commas, as when a proxy appends
+// its own policy to a header. User agents enforce each policy independently,
+// so a resource is only allowed if every policy allows it. Empty policies are
+// skipped, and an error is returned if any policy fails to Parse.
+func ParseAll(header string) ([]Directives, error) {
+	var dss []Directives
+	for _, policy := range strings.Split(header, ",") {
+		if strings.TrimSpace(policy) == "" {
+			continue
+		}
+		ds, err := Parse(policy)
+		if err != nil {
+			return nil, err
+		}
+		dss = append(dss, ds)
+	}
+	return dss, nil
+}
+
+// DirectiveSet records which directives appeared in a parsed policy, keyed by
+// directive name. Directives cannot tell a directive that is not set from
+// one that is set without a value, such as a bare "script-src;", since both
+// leave the field empty; a DirectiveSet holds the names of both the valued
+// and the explicitly empty directives.
+type DirectiveSet map[string]bool
+
+// Has returns true if the directive named dName appeared in the policy.
+func (s DirectiveSet) Has(dName string) bool {
+	return s[dName]
+}
+
+// ParseSet returns the Directives described by header like Parse, along with
+// the DirectiveSet of every directive name which appeared in header,
+// including unknown directives added to Extra.
+func ParseSet(header string) (Directives, DirectiveSet, error) {
+	return parse(header, ParseOptions{})
+}
+
+// parse returns the Directives described by header, configured by opts, and
+// the DirectiveSet of every directive name which appeared in header.
+func parse(header string, opts ParseOptions) (Directives, DirectiveSet, error) {
+	var ds Directives
+	val := reflect.ValueOf(&ds).Elem()
+	seen := make(DirectiveSet)
+	for _, directive := range strings.Split(header, ";") {
+		tokens := strings.Fields(directive)
+		if len(tokens) == 0 {
+			continue
+		}
+		dName := strings.ToLower(tokens[0])
+		if opts.Lenient {
+			dName = strings.TrimRight(dName, ":,")
+		}
+		if !isDirectiveName(dName) {
+			return Directives{}, nil, fmt.Errorf("csp: malformed directive name %q", tokens[0])
+		}
+		if seen[dName] {
+			return Directives{}, nil, fmt.Errorf("csp: duplicate directive %q", dName)
+		}
+		seen[dName] = true
+		name, ok := fieldName[dName]
+		if !ok {
+			if ds.Extra == nil {
+				ds.Extra = make(map[string][]string)
+			}
+			ds.Extra[dName] = tokens[1:]
+			continue
+		}
+		field := val.FieldByName(name)
+		switch field.Kind() {
+		case reflect.Slice:
+			if len(tokens) > 1 {
+				field.Set(reflect.ValueOf(tokens[1:]))
+			}
+		case reflect.String:
+			field.SetString(strings.Join(tokens[1:], " "))
+			if name == "Sandbox" && len(tokens) == 1 {
+				ds.SandboxEmpty = true
+			}
+		case reflect.Bool:
+			field.SetBool(true)
+		}
+	}
+	return ds, seen, nil
+}
+
+// isDirectiveName returns true if s is a syntactically valid directive name,
+// i.e. one or more ASCII letters, digits, or "-".
+func isDirectiveName(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if !('a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9' || r == '-') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,329 @@
+package csp
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	cases := map[string]struct {
+		header string
+		want   Directives
+	}{
+		"empty": {
+			header: "",
+			want:   Directives{},
+		},
+		"single": {
+			header: "default-src acme.com example.com;",
+			want: Directives{
+				DefaultSrc: []string{"acme.com", "example.com"},
+			},
+		},
+		"multiple": {
+			header: "default-src 'self'; report-to jd@example.com; style-src 'self' example.com;",
+			want: Directives{
+				DefaultSrc: []string{"'self'"},
+				ReportTo:   "jd@example.com",
+				StyleSrc:   []string{"'self'", "example.com"},
+			},
+		},
+		"string joins remainder": {
+			header: "sandbox allow-forms allow-scripts",
+			want: Directives{
+				Sandbox: "allow-forms allow-scripts",
+			},
+		},
+		"empty sandbox": {
+			header: "default-src 'self'; sandbox;",
+			want: Directives{
+				DefaultSrc:   []string{"'self'"},
+				SandboxEmpty: true,
+			},
+		},
+		"extra": {
+			header: "default-src 'self'; fenced-frame-src https://example.com; experimental;",
+			want: Directives{
+				DefaultSrc:     []string{"'self'"},
+				FencedFrameSrc: []string{"https://example.com"},
+				Extra: map[string][]string{
+					"experimental": {},
+				},
+			},
+		},
+		"plugin types": {
+			header: "object-src 'self'; plugin-types application/pdf application/x-java-applet",
+			want: Directives{
+				ObjectSrc:   []string{"'self'"},
+				PluginTypes: []string{"application/pdf", "application/x-java-applet"},
+			},
+		},
+		"valueless": {
+			header: "default-src 'self'; upgrade-insecure-requests;",
+			want: Directives{
+				DefaultSrc:              []string{"'self'"},
+				UpgradeInsecureRequests: true,
+			},
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := Parse(c.header)
+			if err != nil {
+				t.Fatalf(errorString, err, nil)
+			}
+			if !reflect.DeepEqual(got, c.want) {
+				t.Fatalf(directivesErrorString, got, c.want)
+			}
+		})
+	}
+}
+
+func TestParseWhitespace(t *testing.T) {
+	want, err := Parse("default-src 'self'; script-src 'self' https://example.com;")
+	if err != nil {
+		t.Fatalf(errorString, err, nil)
+	}
+	cases := map[string]string{
+		"multiple spaces":   "default-src   'self';   script-src  'self'    https://example.com;",
+		"tabs":              "default-src\t'self';\tscript-src\t'self'\t\thttps://example.com;",
+		"space before semi": "default-src 'self' ; script-src 'self' https://example.com ;",
+		"mixed":             "  default-src   'self' ;  script-src\t'self' \t https://example.com  ",
+		"newlines":          "default-src 'self';\n\tscript-src 'self'\r\n\thttps://example.com;\n",
+		"no trailing semi":  "default-src 'self';script-src 'self' https://example.com",
+		"empty directives":  ";; default-src 'self' ;;; script-src 'self' https://example.com ;;",
+	}
+	for name, header := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := Parse(header)
+			if err != nil {
+				t.Fatalf(errorString, err, nil)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf(directivesErrorString, got, want)
+			}
+		})
+	}
+}
+
+func TestParseCaseInsensitive(t *testing.T) {
+	cases := map[string]struct {
+		header string
+		want   Directives
+	}{
+		"mixed case": {
+			header: "DEFAULT-SRC 'self'; Script-Src https://X.com",
+			want: Directives{
+				DefaultSrc: []string{"'self'"},
+				ScriptSrc:  []string{"https://X.com"},
+			},
+		},
+		"valueless": {
+			header: "Upgrade-Insecure-Requests",
+			want: Directives{
+				UpgradeInsecureRequests: true,
+			},
+		},
+		"extra": {
+			header: "Foo-Src Verbatim",
+			want: Directives{
+				Extra: map[string][]string{"foo-src": {"Verbatim"}},
+			},
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := Parse(c.header)
+			if err != nil {
+				t.Fatalf(errorString, err, nil)
+			}
+			if !reflect.DeepEqual(got, c.want) {
+				t.Fatalf(directivesErrorString, got, c.want)
+			}
+		})
+	}
+}
+
+func TestParseAll(t *testing.T) {
+	cases := map[string]struct {
+		header string
+		want   []Directives
+	}{
+		"empty": {},
+		"single": {
+			header: "default-src 'self'; img-src *",
+			want: []Directives{
+				{DefaultSrc: []string{"'self'"}, ImgSrc: []string{"*"}},
+			},
+		},
+		"two policies": {
+			header: "default-src 'self'; script-src 'self', script-src 'none'; object-src 'none'",
+			want: []Directives{
+				{DefaultSrc: []string{"'self'"}, ScriptSrc: []string{"'self'"}},
+				{ScriptSrc: []string{"'none'"}, ObjectSrc: []string{"'none'"}},
+			},
+		},
+		"empty policies": {
+			header: ", default-src 'self',, ",
+			want: []Directives{
+				{DefaultSrc: []string{"'self'"}},
+			},
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := ParseAll(c.header)
+			if err != nil {
+				t.Fatalf(errorString, err, nil)
+			}
+			if !reflect.DeepEqual(got, c.want) {
+				t.Fatalf(errorString, got, c.want)
+			}
+		})
+	}
+}
+
+func TestParseAllError(t *testing.T) {
+	if _, err := ParseAll("default-src 'self', foo_src x"); err == nil {
+		t.Fatalf(errorString, err, "an error")
+	}
+}
+
+func TestParseWith(t *testing.T) {
+	header := "default-src: 'self'; script-src, https://example.com; img-src * ;"
+	want := Directives{
+		DefaultSrc: []string{"'self'"},
+		ImgSrc:     []string{"*"},
+		ScriptSrc:  []string{"https://example.com"},
+	}
+	got, err := ParseWith(header, ParseOptions{Lenient: true})
+	if err != nil {
+		t.Fatalf(errorString, err, nil)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf(directivesErrorString, got, want)
+	}
+	wantErr := `csp: malformed directive name "default-src:"`
+	if _, err := ParseWith(header, ParseOptions{}); err == nil || err.Error() != wantErr {
+		t.Fatalf(errorString, err, wantErr)
+	}
+}
+
+func TestParseWithLenientErrors(t *testing.T) {
+	cases := map[string]string{
+		"only punctuation": "default-src 'self'; :: *",
+		"inner colon":      "default:src 'self'",
+		"duplicate":        "script-src: 'self'; script-src 'none'",
+	}
+	for name, header := range cases {
+		t.Run(name, func(t *testing.T) {
+			if _, err := ParseWith(header, ParseOptions{Lenient: true}); err == nil {
+				t.Fatalf(errorString, err, "an error")
+			}
+		})
+	}
+}
+
+func TestParseSet(t *testing.T) {
+	ds, set, err := ParseSet("default-src 'self'; script-src; upgrade-insecure-requests; foo-src")
+	if err != nil {
+		t.Fatalf(errorString, err, nil)
+	}
+	wantDs := Directives{
+		DefaultSrc:              []string{"'self'"},
+		UpgradeInsecureRequests: true,
+		Extra:                   map[string][]string{"foo-src": {}},
+	}
+	if !reflect.DeepEqual(ds, wantDs) {
+		t.Fatalf(directivesErrorString, ds, wantDs)
+	}
+	wantSet := DirectiveSet{
+		"default-src":               true,
+		"foo-src":                   true,
+		"script-src":                true,
+		"upgrade-insecure-requests": true,
+	}
+	if !reflect.DeepEqual(set, wantSet) {
+		t.Fatalf(errorString, set, wantSet)
+	}
+	if !set.Has("script-src") || set.Has("style-src") {
+		t.Fatalf(errorString, set, "script-src but not style-src")
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	cases := map[string]struct {
+		header string
+		want   string
+	}{
+		"malformed directive": {
+			header: "default-src 'self'; foo_src example.com",
+			want:   `malformed directive name "foo_src"`,
+		},
+		"duplicate directive": {
+			header: "script-src 'self'; script-src example.com",
+			want:   `duplicate directive "script-src"`,
+		},
+		"duplicate directive case": {
+			header: "script-src 'self'; Script-Src example.com",
+			want:   `duplicate directive "script-src"`,
+		},
+		"duplicate extra": {
+			header: "foo-src 'self'; foo-src example.com",
+			want:   `duplicate directive "foo-src"`,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := Parse(c.header)
+			if err == nil || !strings.Contains(err.Error(), c.want) {
+				t.Fatalf(errorString, err, c.want)
+			}
+		})
+	}
+}
+
+func TestParseRoundTrip(t *testing.T) {
+	cases := map[string]string{
+		"basic":       Basic(),
+		"basic tight": BasicTight(),
+		"valueless":   "block-all-mixed-content; default-src 'self'; upgrade-insecure-requests;",
+		"extra":       "default-src 'self'; a-src Verbatim; b-directive;",
+	}
+	for name, policy := range cases {
+		t.Run(name, func(t *testing.T) {
+			ds, err := Parse(policy)
+			if err != nil {
+				t.Fatalf(errorString, err, nil)
+			}
+			if got := Policy(ds); got != policy {
+				t.Fatalf(errorString, got, policy)
+			}
+		})
+	}
+}
+
+func FuzzRoundTrip(f *testing.F) {
+	f.Add(Basic())
+	f.Add(BasicTight())
+	f.Add(Policy(fullDirectives))
+	f.Add("default-src SELF https://Example.com; foo-src Verbatim;;")
+	f.Fuzz(func(t *testing.T, header string) {
+		first, err := Parse(header)
+		if err != nil {
+			return
+		}
+		policy := Policy(first)
+		second, err := Parse(policy)
+		if err != nil {
+			t.Fatalf(errorString, err, nil)
+		}
+		if !Equal(first, second) {
+			t.Fatalf(directivesErrorString, second, first)
+		}
+		if got := Policy(second); got != policy {
+			t.Fatalf(errorString, got, policy)
+		}
+	})
+}
@@ -0,0 +1,37 @@
+package csp
+
+import (
+	"fmt"
+	"sync"
+)
+
+// presets maps preset names to the functions returning their policies.
+var (
+	presetsMu sync.RWMutex
+	presets   = map[string]func() string{
+		"basic":       Basic,
+		"basic-tight": BasicTight,
+	}
+)
+
+// Preset returns the policy of the preset registered under name, or an error
+// if there is none. Basic is registered as "basic" and BasicTight as
+// "basic-tight". Strict is not registered as it requires a per-request nonce.
+func Preset(name string) (string, error) {
+	presetsMu.RLock()
+	fn, ok := presets[name]
+	presetsMu.RUnlock()
+	if !ok {
+		return "", fmt.Errorf("csp: unknown preset %q", name)
+	}
+	return fn(), nil
+}
+
+// RegisterPreset registers fn as the preset returned by Preset for name,
+// replacing any preset already registered under name. It is safe to call
+// concurrently with Preset.
+func RegisterPreset(name string, fn func() string) {
+	presetsMu.Lock()
+	defer presetsMu.Unlock()
+	presets[name] = fn
+}
@@ -0,0 +1,56 @@
+package csp
+
+import "testing"
+
+func TestPreset(t *testing.T) {
+	cases := map[string]struct {
+		name string
+		want string
+		err  bool
+	}{
+		"basic": {
+			name: "basic",
+			want: Basic(),
+		},
+		"basic-tight": {
+			name: "basic-tight",
+			want: BasicTight(),
+		},
+		"unknown": {
+			name: "strict",
+			err:  true,
+		},
+		"case sensitive": {
+			name: "Basic",
+			err:  true,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := Preset(c.name)
+			if (err != nil) != c.err {
+				t.Fatalf(errorString, err, c.err)
+			}
+			if got != c.want {
+				t.Fatalf(errorString, got, c.want)
+			}
+		})
+	}
+}
+
+func TestRegisterPreset(t *testing.T) {
+	want := "default-src 'self'; img-src *;"
+	RegisterPreset("test-images", func() string { return want })
+	t.Cleanup(func() {
+		presetsMu.Lock()
+		delete(presets, "test-images")
+		presetsMu.Unlock()
+	})
+	got, err := Preset("test-images")
+	if err != nil {
+		t.Fatalf(errorString, err, nil)
+	}
+	if got != want {
+		t.Fatalf(errorString, got, want)
+	}
+}
@@ -0,0 +1,297 @@
+package csp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// ReportToHeaderKey is the canonical form of the Report-To header key, which
+// defines the endpoint groups named by the report-to directive.
+const ReportToHeaderKey = "Report-To"
+
+// ReportingEndpointsHeaderKey is the canonical form of the
+// Reporting-Endpoints header key, which names the endpoints used by the
+// report-to directive. It supersedes the Report-To header.
+const ReportingEndpointsHeaderKey = "Reporting-Endpoints"
+
+// ReportingEndpoint is an endpoint group of the Report-To header.
+type ReportingEndpoint struct {
+	// Group is the name of the group, as used by the report-to directive.
+	Group string
+
+	// MaxAge is the number of seconds for which the user agent should use the
+	// group.
+	MaxAge int
+
+	// Endpoints are the URLs to which reports for the group are sent.
+	Endpoints []string
+}
+
+// reportToGroup is the JSON form of a ReportingEndpoint.
+type reportToGroup struct {
+	Group     string          `json:"group,omitempty"`
+	MaxAge    int             `json:"max_age"`
+	Endpoints []reportToIndex `json:"endpoints"`
+}
+
+// reportToIndex is the JSON form of a single endpoint URL.
+type reportToIndex struct {
+	URL string `json:"url"`
+}
+
+// ReportToHeader returns the value of a Report-To header defining every
+// group in endpoints, e.g.
+//
+//	{"group":"csp-endpoint","max_age":10886400,"endpoints":[{"url":"https://example.com/csp-reports"}]}
+//
+// Multiple groups are comma separated.
+func ReportToHeader(endpoints ...ReportingEndpoint) string {
+	groups := make([]string, 0, len(endpoints))
+	for _, e := range endpoints {
+		g := reportToGroup{Group: e.Group, MaxAge: e.MaxAge, Endpoints: make([]reportToIndex, len(e.Endpoints))}
+		for i, url := range e.Endpoints {
+			g.Endpoints[i] = reportToIndex{URL: url}
+		}
+		b, err := json.Marshal(g)
+		if err != nil {
+			continue
+		}
+		groups = append(groups, string(b))
+	}
+	return strings.Join(groups, ", ")
+}
+
+// ReportingEndpointsHeader returns the value of a Reporting-Endpoints header
+// mapping each endpoint name in endpoints to its URL, e.g.
+//
+//	csp-endpoint="https://example.com/csp-reports", default="https://example.com/reports"
+//
+// Endpoints are sorted by name. An error is returned if a name is not a
+// structured field key, which starts with a lowercase letter or * followed by
+// lowercase letters, digits, _, -, ., or *. URLs are serialized as structured
+// field strings: backslashes and double quotes are escaped, and bytes which
+// are not printable ASCII are percent-encoded.
+func ReportingEndpointsHeader(endpoints map[string]string) (string, error) {
+	names := make([]string, 0, len(endpoints))
+	for name := range endpoints {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	members := make([]string, len(names))
+	for i, name := range names {
+		if !isSFKey(name) {
+			return "", fmt.Errorf("csp: invalid endpoint name %q", name)
+		}
+		members[i] = name + "=" + sfString(endpoints[name])
+	}
+	return strings.Join(members, ", "), nil
+}
+
+// isSFKey returns true if s is a structured field key, see RFC 8941.
+func isSFKey(s string) bool {
+	if s == "" || !('a' <= s[0] && s[0] <= 'z' || s[0] == '*') {
+		return false
+	}
+	for i := 1; i < len(s); i++ {
+		if c := s[i]; !('a' <= c && c <= 'z' || '0' <= c && c <= '9' || strings.IndexByte("_-.*", c) >= 0) {
+			return false
+		}
+	}
+	return true
+}
+
+// sfString returns s as a quoted structured field string.
+func sfString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '\\' || c == '"':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c < 0x20 || c > 0x7e:
+			fmt.Fprintf(&b, "%%%02X", c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// Report is a CSP violation report sent by a user agent.
+type Report struct {
+	DocumentURI        string `json:"document-uri"`
+	Referrer           string `json:"referrer"`
+	BlockedURI         string `json:"blocked-uri"`
+	ViolatedDirective  string `json:"violated-directive"`
+	EffectiveDirective string `json:"effective-directive"`
+	OriginalPolicy     string `json:"original-policy"`
+	Disposition        string `json:"disposition"`
+	SourceFile         string `json:"source-file"`
+	LineNumber         int    `json:"line-number"`
+	ColumnNumber       int    `json:"column-number"`
+	StatusCode         int    `json:"status-code"`
+	ScriptSample       string `json:"script-sample"`
+}
+
+// reportBody is the body of a CSP violation report sent using the Reporting
+// API.
+type reportBody struct {
+	DocumentURL        string `json:"documentURL"`
+	Referrer           string `json:"referrer"`
+	BlockedURL         string `json:"blockedURL"`
+	EffectiveDirective string `json:"effectiveDirective"`
+	OriginalPolicy     string `json:"originalPolicy"`
+	Disposition        string `json:"disposition"`
+	SourceFile         string `json:"sourceFile"`
+	LineNumber         int    `json:"lineNumber"`
+	ColumnNumber       int    `json:"columnNumber"`
+	StatusCode         int    `json:"statusCode"`
+	Sample             string `json:"sample"`
+}
+
+// report returns b as a Report. The Reporting API has no violated directive,
+// so ViolatedDirective is set to the effective directive.
+func (b reportBody) report() Report {
+	return Report{
+		DocumentURI:        b.DocumentURL,
+		Referrer:           b.Referrer,
+		BlockedURI:         b.BlockedURL,
+		ViolatedDirective:  b.EffectiveDirective,
+		EffectiveDirective: b.EffectiveDirective,
+		OriginalPolicy:     b.OriginalPolicy,
+		Disposition:        b.Disposition,
+		SourceFile:         b.SourceFile,
+		LineNumber:         b.LineNumber,
+		ColumnNumber:       b.ColumnNumber,
+		StatusCode:         b.StatusCode,
+		ScriptSample:       b.Sample,
+	}
+}
+
+// reportEnvelope holds either form of a CSP violation report.
+type reportEnvelope struct {
+	CSPReport *Report     `json:"csp-report"`
+	Type      string      `json:"type"`
+	Body      *reportBody `json:"body"`
+}
+
+// errNotReport is returned when JSON is not a CSP violation report.
+var errNotReport = errors.New("csp: not a CSP violation report")
+
+// report returns the Report held by e.
+func (e reportEnvelope) report() (Report, error) {
+	switch {
+	case e.CSPReport != nil:
+		return *e.CSPReport, nil
+	case e.Type == "csp-violation" && e.Body != nil:
+		return e.Body.report(), nil
+	}
+	return Report{}, errNotReport
+}
+
+// ParseReport returns the CSP violation report read from r. Both the legacy
+// report-uri form, where the report is held under a "csp-report" key, and the
+// Reporting API form, where a report of type "csp-violation" is held under a
+// "body" key, are accepted.
+func ParseReport(r io.Reader) (Report, error) {
+	var e reportEnvelope
+	if err := json.NewDecoder(r).Decode(&e); err != nil {
+		return Report{}, err
+	}
+	return e.report()
+}
+
+// ParseReports returns the CSP violation reports read from r, a Reporting API
+// batch as sent with the application/reports+json content type. Reports in
+// the batch which are not CSP violations are skipped.
+func ParseReports(r io.Reader) ([]Report, error) {
+	var es []reportEnvelope
+	if err := json.NewDecoder(r).Decode(&es); err != nil {
+		return nil, err
+	}
+	reports := make([]Report, 0, len(es))
+	for _, e := range es {
+		if report, err := e.report(); err == nil {
+			reports = append(reports, report)
+		}
+	}
+	return reports, nil
+}
+
+// maxReportSize is the maximum size in bytes of a request body accepted by
+// ReportHandler.
+const maxReportSize = 64 << 10
+
+// ReportHandler returns a handler for CSP violation reports which calls fn
+// for each report received. It accepts POST requests with the
+// application/csp-report (or application/json) content type used for
+// report-uri, and the application/reports+json content type used for
+// report-to. It responds with 204 No Content on success, 400 Bad Request for
+// a malformed report, 405 Method Not Allowed for other methods, and 415
+// Unsupported Media Type for other content types.
+func ReportHandler(fn func(Report)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		body := http.MaxBytesReader(w, r.Body, maxReportSize)
+		var reports []Report
+		mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		switch mediaType {
+		case "application/csp-report", "application/json":
+			report, err := ParseReport(body)
+			if err != nil {
+				http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+				return
+			}
+			reports = append(reports, report)
+		case "application/reports+json":
+			var err error
+			if reports, err = ParseReports(body); err != nil {
+				http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+				return
+			}
+		default:
+			http.Error(w, http.StatusText(http.StatusUnsupportedMediaType), http.StatusUnsupportedMediaType)
+			return
+		}
+		for _, report := range reports {
+			fn(report)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// ReportHandlerWithLogger returns a handler like ReportHandler which logs
+// each report received to logger at the Info level, with the attributes
+// document_uri, blocked_uri, violated_directive, effective_directive,
+// disposition, source_file, and line_number. slog.Default is used if logger
+// is nil.
+func ReportHandlerWithLogger(logger *slog.Logger) http.Handler {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return ReportHandler(func(r Report) {
+		logger.LogAttrs(context.Background(), slog.LevelInfo, "csp violation",
+			slog.String("document_uri", r.DocumentURI),
+			slog.String("blocked_uri", r.BlockedURI),
+			slog.String("violated_directive", r.ViolatedDirective),
+			slog.String("effective_directive", r.EffectiveDirective),
+			slog.String("disposition", r.Disposition),
+			slog.String("source_file", r.SourceFile),
+			slog.Int("line_number", r.LineNumber),
+		)
+	})
+}
@@ -0,0 +1,369 @@
+package csp
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReportToHeader(t *testing.T) {
+	cases := map[string]struct {
+		endpoints []ReportingEndpoint
+		want      string
+	}{
+		"none": {
+			want: "",
+		},
+		"single": {
+			endpoints: []ReportingEndpoint{{
+				Group:     "csp-endpoint",
+				MaxAge:    10886400,
+				Endpoints: []string{"https://example.com/csp-reports"},
+			}},
+			want: `{"group":"csp-endpoint","max_age":10886400,"endpoints":[{"url":"https://example.com/csp-reports"}]}`,
+		},
+		"multiple": {
+			endpoints: []ReportingEndpoint{
+				{
+					Group:     "csp-endpoint",
+					MaxAge:    86400,
+					Endpoints: []string{"https://a.example.com/r", "https://b.example.com/r"},
+				},
+				{
+					MaxAge:    86400,
+					Endpoints: []string{"https://example.com/default"},
+				},
+			},
+			want: `{"group":"csp-endpoint","max_age":86400,"endpoints":[{"url":"https://a.example.com/r"},{"url":"https://b.example.com/r"}]}, {"max_age":86400,"endpoints":[{"url":"https://example.com/default"}]}`,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			if got := ReportToHeader(c.endpoints...); got != c.want {
+				t.Fatalf(errorString, got, c.want)
+			}
+		})
+	}
+}
+
+func TestReportToHeaderJSON(t *testing.T) {
+	header := ReportToHeader(ReportingEndpoint{
+		Group:     "csp-endpoint",
+		MaxAge:    60,
+		Endpoints: []string{"https://example.com/csp-reports?a=1&b=2"},
+	})
+	var got map[string]any
+	if err := json.Unmarshal([]byte(header), &got); err != nil {
+		t.Fatalf(errorString, err, nil)
+	}
+	want := map[string]any{
+		"group":     "csp-endpoint",
+		"max_age":   float64(60),
+		"endpoints": []any{map[string]any{"url": "https://example.com/csp-reports?a=1&b=2"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf(errorString, got, want)
+	}
+}
+
+func TestReportingEndpointsHeader(t *testing.T) {
+	cases := map[string]struct {
+		endpoints map[string]string
+		want      string
+	}{
+		"none": {
+			want: "",
+		},
+		"multiple": {
+			endpoints: map[string]string{
+				"default":      "https://example.com/reports",
+				"csp-endpoint": "https://example.com/csp-reports",
+			},
+			want: `csp-endpoint="https://example.com/csp-reports", default="https://example.com/reports"`,
+		},
+		"key characters": {
+			endpoints: map[string]string{
+				"*csp_endpoint.v2-a": "https://example.com/csp-reports",
+			},
+			want: `*csp_endpoint.v2-a="https://example.com/csp-reports"`,
+		},
+		"special characters": {
+			endpoints: map[string]string{
+				"csp-endpoint": `https://example.com/r?q="a\\b"&c=d e`,
+			},
+			want: `csp-endpoint="https://example.com/r?q=\"a\\\\b\"&c=d e"`,
+		},
+		"non ascii": {
+			endpoints: map[string]string{
+				"csp-endpoint": "https://example.com/ré\n",
+			},
+			want: `csp-endpoint="https://example.com/r%C3%A9%0A"`,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := ReportingEndpointsHeader(c.endpoints)
+			if err != nil {
+				t.Fatalf(errorString, err, nil)
+			}
+			if got != c.want {
+				t.Fatalf(errorString, got, c.want)
+			}
+		})
+	}
+}
+
+func TestReportingEndpointsHeaderErrors(t *testing.T) {
+	cases := map[string]map[string]string{
+		"space":     {"CSP Endpoint": "https://example.com/csp-reports"},
+		"uppercase": {"Default": "https://example.com/reports"},
+		"digit":     {"1st": "https://example.com/reports"},
+		"separator": {"default": "https://example.com/reports", "a,b": "https://example.com/reports"},
+		"empty":     {"": "https://example.com/reports"},
+	}
+	for name, endpoints := range cases {
+		t.Run(name, func(t *testing.T) {
+			if got, err := ReportingEndpointsHeader(endpoints); err == nil {
+				t.Fatalf(errorString, got, "error")
+			}
+		})
+	}
+}
+
+// legacyReport is a violation report as sent to a report-uri endpoint.
+const legacyReport = `{
+  "csp-report": {
+    "document-uri": "https://example.com/page",
+    "referrer": "https://example.com/",
+    "violated-directive": "script-src-elem",
+    "effective-directive": "script-src-elem",
+    "original-policy": "default-src 'self'; report-uri /csp-reports",
+    "disposition": "enforce",
+    "blocked-uri": "https://evil.example.net/x.js",
+    "line-number": 12,
+    "column-number": 3,
+    "source-file": "https://example.com/app.js",
+    "status-code": 200,
+    "script-sample": ""
+  }
+}`
+
+// reportingAPIReport is a violation report as sent to a report-to endpoint.
+const reportingAPIReport = `{
+  "age": 53531,
+  "type": "csp-violation",
+  "url": "https://example.com/page",
+  "user_agent": "Mozilla/5.0",
+  "body": {
+    "blockedURL": "inline",
+    "columnNumber": 39,
+    "disposition": "enforce",
+    "documentURL": "https://example.com/page",
+    "effectiveDirective": "script-src-elem",
+    "lineNumber": 121,
+    "originalPolicy": "default-src 'self'; report-to csp-endpoint",
+    "referrer": "https://www.google.com/",
+    "sample": "console.log(\"lo\")",
+    "sourceFile": "https://example.com/page",
+    "statusCode": 200
+  }
+}`
+
+func TestParseReport(t *testing.T) {
+	cases := map[string]struct {
+		body string
+		want Report
+	}{
+		"legacy": {
+			body: legacyReport,
+			want: Report{
+				DocumentURI:        "https://example.com/page",
+				Referrer:           "https://example.com/",
+				BlockedURI:         "https://evil.example.net/x.js",
+				ViolatedDirective:  "script-src-elem",
+				EffectiveDirective: "script-src-elem",
+				OriginalPolicy:     "default-src 'self'; report-uri /csp-reports",
+				Disposition:        "enforce",
+				SourceFile:         "https://example.com/app.js",
+				LineNumber:         12,
+				ColumnNumber:       3,
+				StatusCode:         200,
+			},
+		},
+		"reporting api": {
+			body: reportingAPIReport,
+			want: Report{
+				DocumentURI:        "https://example.com/page",
+				Referrer:           "https://www.google.com/",
+				BlockedURI:         "inline",
+				ViolatedDirective:  "script-src-elem",
+				EffectiveDirective: "script-src-elem",
+				OriginalPolicy:     "default-src 'self'; report-to csp-endpoint",
+				Disposition:        "enforce",
+				SourceFile:         "https://example.com/page",
+				LineNumber:         121,
+				ColumnNumber:       39,
+				StatusCode:         200,
+				ScriptSample:       `console.log("lo")`,
+			},
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := ParseReport(strings.NewReader(c.body))
+			if err != nil {
+				t.Fatalf(errorString, err, nil)
+			}
+			if got != c.want {
+				t.Fatalf(errorString, got, c.want)
+			}
+		})
+	}
+}
+
+func TestParseReportErrors(t *testing.T) {
+	cases := map[string]string{
+		"malformed":    `{"csp-report": `,
+		"empty":        `{}`,
+		"other report": `{"type": "deprecation", "body": {}}`,
+	}
+	for name, body := range cases {
+		t.Run(name, func(t *testing.T) {
+			if _, err := ParseReport(strings.NewReader(body)); err == nil {
+				t.Fatalf(errorString, err, "error")
+			}
+		})
+	}
+}
+
+func TestParseReports(t *testing.T) {
+	body := "[" + reportingAPIReport + `, {"type": "deprecation", "body": {}}, ` + reportingAPIReport + "]"
+	got, err := ParseReports(strings.NewReader(body))
+	if err != nil {
+		t.Fatalf(errorString, err, nil)
+	}
+	if len(got) != 2 || got[0].EffectiveDirective != "script-src-elem" {
+		t.Fatalf(errorString, got, "two script-src-elem reports")
+	}
+}
+
+func TestReportHandler(t *testing.T) {
+	cases := map[string]struct {
+		method      string
+		contentType string
+		body        string
+		wantStatus  int
+		wantReports []string
+	}{
+		"csp-report": {
+			method:      http.MethodPost,
+			contentType: "application/csp-report",
+			body:        legacyReport,
+			wantStatus:  http.StatusNoContent,
+			wantReports: []string{"script-src-elem"},
+		},
+		"reports+json": {
+			method:      http.MethodPost,
+			contentType: "application/reports+json; charset=utf-8",
+			body:        "[" + reportingAPIReport + "," + reportingAPIReport + "]",
+			wantStatus:  http.StatusNoContent,
+			wantReports: []string{"script-src-elem", "script-src-elem"},
+		},
+		"malformed": {
+			method:      http.MethodPost,
+			contentType: "application/csp-report",
+			body:        `{"csp-report": `,
+			wantStatus:  http.StatusBadRequest,
+		},
+		"malformed batch": {
+			method:      http.MethodPost,
+			contentType: "application/reports+json",
+			body:        legacyReport,
+			wantStatus:  http.StatusBadRequest,
+		},
+		"wrong method": {
+			method:     http.MethodGet,
+			wantStatus: http.StatusMethodNotAllowed,
+		},
+		"wrong content type": {
+			method:      http.MethodPost,
+			contentType: "text/plain",
+			body:        legacyReport,
+			wantStatus:  http.StatusUnsupportedMediaType,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			var got []string
+			h := ReportHandler(func(r Report) {
+				got = append(got, r.ViolatedDirective)
+			})
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(c.method, "/csp-reports", strings.NewReader(c.body))
+			r.Header.Set("Content-Type", c.contentType)
+			h.ServeHTTP(w, r)
+			if w.Code != c.wantStatus {
+				t.Fatalf(errorString, w.Code, c.wantStatus)
+			}
+			if !reflect.DeepEqual(got, c.wantReports) {
+				t.Fatalf(errorString, got, c.wantReports)
+			}
+		})
+	}
+}
+
+// recordHandler is a slog.Handler which records the attributes of every
+// record it handles.
+type recordHandler struct {
+	levels []slog.Level
+	attrs  []map[string]any
+}
+
+func (h *recordHandler) Enabled(context.Context, slog.Level) bool { return true }
+
+func (h *recordHandler) Handle(_ context.Context, r slog.Record) error {
+	attrs := make(map[string]any)
+	r.Attrs(func(a slog.Attr) bool {
+		attrs[a.Key] = a.Value.Any()
+		return true
+	})
+	h.levels = append(h.levels, r.Level)
+	h.attrs = append(h.attrs, attrs)
+	return nil
+}
+
+func (h *recordHandler) WithAttrs([]slog.Attr) slog.Handler { return h }
+
+func (h *recordHandler) WithGroup(string) slog.Handler { return h }
+
+func TestReportHandlerWithLogger(t *testing.T) {
+	rec := &recordHandler{}
+	h := ReportHandlerWithLogger(slog.New(rec))
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/csp-reports", strings.NewReader(legacyReport))
+	r.Header.Set("Content-Type", "application/csp-report")
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusNoContent {
+		t.Fatalf(errorString, w.Code, http.StatusNoContent)
+	}
+	if want := []slog.Level{slog.LevelInfo}; !reflect.DeepEqual(rec.levels, want) {
+		t.Fatalf(errorString, rec.levels, want)
+	}
+	want := []map[string]any{{
+		"document_uri":        "https://example.com/page",
+		"blocked_uri":         "https://evil.example.net/x.js",
+		"violated_directive":  "script-src-elem",
+		"effective_directive": "script-src-elem",
+		"disposition":         "enforce",
+		"source_file":         "https://example.com/app.js",
+		"line_number":         int64(12),
+	}}
+	if !reflect.DeepEqual(rec.attrs, want) {
+		t.Fatalf(errorString, rec.attrs, want)
+	}
+}
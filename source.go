@@ -0,0 +1,267 @@
+package csp
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Sources is the source list of a single directive. Its methods compare
+// sources after canonicalization, so "self" matches "'self'". As a []string,
+// Sources may be assigned to the fields of Directives and built from them by
+// conversion, e.g. Sources(ds.ScriptSrc).
+type Sources []string
+
+// Add appends the canonical form of every source not already contained in s.
+func (s *Sources) Add(sources ...string) {
+	for _, src := range sources {
+		if !s.Contains(src) {
+			*s = append(*s, canon(src))
+		}
+	}
+}
+
+// Remove removes every occurrence of each source from s.
+func (s *Sources) Remove(sources ...string) {
+	cs := canons(sources)
+	*s = slices.DeleteFunc(*s, func(src string) bool {
+		return slices.Contains(cs, canon(src))
+	})
+}
+
+// Contains returns true if s contains source.
+func (s Sources) Contains(source string) bool {
+	c := canon(source)
+	return slices.ContainsFunc(s, func(src string) bool {
+		return canon(src) == c
+	})
+}
+
+// Canon returns the canonicalized sources of s without duplicates.
+func (s Sources) Canon() Sources {
+	return dedup(canons(s))
+}
+
+// nonceSize is the number of random bytes used to create a nonce.
+const nonceSize = 16
+
+// Nonce returns a base64 encoded nonce made from 16 cryptographically random
+// bytes. A fresh nonce should be created for every response, and the same
+// nonce must appear in both the policy (see NonceSource) and the nonce
+// attribute of every permitted <script> or <style> element.
+func Nonce() (string, error) {
+	b := make([]byte, nonceSize)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(b), nil
+}
+
+// NonceSource returns nonce as a nonce-source, i.e. "'nonce-<nonce>'", ready
+// to be appended to a directive such as ScriptSrc or StyleSrc.
+func NonceSource(nonce string) string {
+	return "'nonce-" + nonce + "'"
+}
+
+// Quote returns s enclosed in single-quotes, as keyword, nonce, and hash
+// sources are, e.g. "self" becomes "'self'". s is returned unchanged if it is
+// already enclosed in single-quotes.
+func Quote(s string) string {
+	if isQuoted(s) {
+		return s
+	}
+	return "'" + s + "'"
+}
+
+// Unquote returns s without a single pair of enclosing single-quotes, e.g.
+// "'self'" becomes "self". s is returned unchanged if it is not enclosed in
+// single-quotes.
+func Unquote(s string) string {
+	if isQuoted(s) {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
+
+// isQuoted returns true if s is enclosed in single-quotes.
+func isQuoted(s string) bool {
+	return len(s) > 1 && s[0] == '\'' && s[len(s)-1] == '\''
+}
+
+// Common scheme-sources used in directive values.
+const (
+	SchemeHTTP        = "http:"
+	SchemeHTTPS       = "https:"
+	SchemeWS          = "ws:"
+	SchemeWSS         = "wss:"
+	SchemeData        = "data:"
+	SchemeBlob        = "blob:"
+	SchemeMediaStream = "mediastream:"
+	SchemeFilesystem  = "filesystem:"
+)
+
+// SchemeSource returns scheme as a scheme-source, lowered and with a trailing
+// colon appended if missing, e.g. "https" becomes "https:". An error is
+// returned if scheme is not a plausible URL scheme, such as "http:/".
+func SchemeSource(scheme string) (string, error) {
+	s := strings.ToLower(strings.TrimSpace(scheme))
+	if !strings.HasSuffix(s, ":") {
+		s += ":"
+	}
+	if !isSchemeSource(s) {
+		return "", fmt.Errorf("csp: invalid scheme %q", scheme)
+	}
+	return s, nil
+}
+
+// isSchemeSource returns true if s is a scheme followed by a colon, where a
+// scheme is a letter followed by any letters, digits, "+", "-", or ".".
+func isSchemeSource(s string) bool {
+	scheme, ok := strings.CutSuffix(s, ":")
+	if !ok || scheme == "" {
+		return false
+	}
+	for i, r := range scheme {
+		switch {
+		case 'a' <= r && r <= 'z', 'A' <= r && r <= 'Z':
+		case i > 0 && ('0' <= r && r <= '9' || r == '+' || r == '-' || r == '.'):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// ValidHostSource returns true if s is a syntactically valid host-source of
+// the form [scheme "://"] host [":" port] [path]. The host may be "*" alone,
+// may start with a "*." wildcard as in "*.example.com", and may be bare as in
+// "example.com". A wildcard not followed by a dot, as in "*example.com", and
+// empty labels, as in "example..com", are invalid.
+func ValidHostSource(s string) bool {
+	rest := s
+	if scheme, after, ok := strings.Cut(s, "://"); ok {
+		if !isSchemeSource(scheme + ":") {
+			return false
+		}
+		rest = after
+	}
+	host, path := rest, ""
+	if i := strings.IndexByte(rest, '/'); i >= 0 {
+		host, path = rest[:i], rest[i:]
+	}
+	if h, port, ok := strings.Cut(host, ":"); ok {
+		if !validPort(port) {
+			return false
+		}
+		host = h
+	}
+	return validHost(host) && validPath(path)
+}
+
+// validHost returns true if s is "*" or a dot separated list of labels made
+// of letters, digits, and "-", optionally starting with "*." and ending in
+// ".".
+func validHost(s string) bool {
+	if s == "*" {
+		return true
+	}
+	s = strings.TrimPrefix(s, "*.")
+	s = strings.TrimSuffix(s, ".")
+	if s == "" {
+		return false
+	}
+	for _, label := range strings.Split(s, ".") {
+		if label == "" {
+			return false
+		}
+		for _, r := range label {
+			if !('a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9' || r == '-') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// validPort returns true if s is "*" or one or more digits.
+func validPort(s string) bool {
+	if s == "*" {
+		return true
+	}
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// validPath returns true if s is empty or an absolute path without white
+// space, quotes, or the characters ";" and "," which delimit policies.
+func validPath(s string) bool {
+	if s == "" {
+		return true
+	}
+	return s[0] == '/' && !strings.ContainsAny(s, " \t\n\r\f;,'\"")
+}
+
+// isNonceSource returns true if s is a nonce-source such as "'nonce-abc'".
+func isNonceSource(s string) bool {
+	return strings.HasPrefix(s, "'nonce-") && strings.HasSuffix(s, "'") && len(s) > len("'nonce-'")
+}
+
+// isHashSource returns true if s is a hash-source such as "'sha256-abc'".
+func isHashSource(s string) bool {
+	for _, algo := range []string{HashSHA256, HashSHA384, HashSHA512} {
+		if prefix := "'" + algo + "-"; strings.HasPrefix(s, prefix) && strings.HasSuffix(s, "'") && len(s) > len(prefix)+1 {
+			return true
+		}
+	}
+	return false
+}
+
+// isMisquoted returns true if s is enclosed in single-quotes but is neither a
+// keyword-source, in any case, nor a nonce-source or hash-source, such as a
+// host-source wrongly quoted like "'https://example.com'".
+func isMisquoted(s string) bool {
+	c := canon(s)
+	return isQuoted(c) && !IsKeywordSource(c) && !isNonceSource(c) && !isHashSource(c)
+}
+
+// Acceptable hash algorithms used in hash-sources.
+const (
+	HashSHA256 = "sha256"
+	HashSHA384 = "sha384"
+	HashSHA512 = "sha512"
+)
+
+// HashSource returns the hash-source of content, i.e. "'<algo>-<digest>'",
+// where digest is the base64 encoded hash of content using algo. The content
+// is hashed byte-for-byte without trimming, since user agents hash the exact
+// text of an inline element. An error is returned if algo is not one of
+// sha256, sha384, or sha512.
+func HashSource(algo, content string) (string, error) {
+	var sum []byte
+	algo = strings.ToLower(algo)
+	switch algo {
+	case HashSHA256:
+		h := sha256.Sum256([]byte(content))
+		sum = h[:]
+	case HashSHA384:
+		h := sha512.Sum384([]byte(content))
+		sum = h[:]
+	case HashSHA512:
+		h := sha512.Sum512([]byte(content))
+		sum = h[:]
+	default:
+		return "", fmt.Errorf("csp: unsupported hash algorithm %q (want %s, %s, or %s)", algo, HashSHA256, HashSHA384, HashSHA512)
+	}
+	return "'" + algo + "-" + base64.StdEncoding.EncodeToString(sum) + "'", nil
+}
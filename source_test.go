@@ -0,0 +1,299 @@
+package csp
+
+import (
+	"encoding/base64"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestNonce(t *testing.T) {
+	a, err := Nonce()
+	if err != nil {
+		t.Fatalf(errorString, err, nil)
+	}
+	b, err := base64.StdEncoding.DecodeString(a)
+	if err != nil {
+		t.Fatalf(errorString, err, nil)
+	}
+	if got, want := len(b), nonceSize; got != want {
+		t.Fatalf(errorString, got, want)
+	}
+	if z, _ := Nonce(); z == a {
+		t.Fatalf(errorString, z, "a different nonce")
+	}
+}
+
+func TestNonceSource(t *testing.T) {
+	cases := map[string]struct {
+		nonce string
+		want  string
+	}{
+		"nonce": {
+			nonce: "r4nd0m",
+			want:  "'nonce-r4nd0m'",
+		},
+		"base64": {
+			nonce: "YWJjZA+/=",
+			want:  "'nonce-YWJjZA+/='",
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			if got := NonceSource(c.nonce); got != c.want {
+				t.Fatalf(errorString, got, c.want)
+			}
+		})
+	}
+}
+
+func TestQuote(t *testing.T) {
+	cases := map[string]struct {
+		s      string
+		quoted string
+		bare   string
+	}{
+		"empty":       {s: "", quoted: "''", bare: ""},
+		"keyword":     {s: "self", quoted: "'self'", bare: "self"},
+		"quoted":      {s: "'self'", quoted: "'self'", bare: "self"},
+		"nonce":       {s: "nonce-abc", quoted: "'nonce-abc'", bare: "nonce-abc"},
+		"single":      {s: "'", quoted: "'''", bare: "'"},
+		"half quoted": {s: "'self", quoted: "''self'", bare: "'self"},
+		"twice":       {s: "''self''", quoted: "''self''", bare: "'self'"},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			if got := Quote(c.s); got != c.quoted {
+				t.Fatalf(errorString, got, c.quoted)
+			}
+			if got := Quote(Quote(c.s)); got != c.quoted {
+				t.Fatalf(errorString, got, c.quoted)
+			}
+			if got := Unquote(c.s); got != c.bare {
+				t.Fatalf(errorString, got, c.bare)
+			}
+		})
+	}
+}
+
+func TestHashSource(t *testing.T) {
+	cases := map[string]struct {
+		algo    string
+		content string
+		want    string
+	}{
+		"sha256": {
+			algo:    HashSHA256,
+			content: "alert('Hello, world.');",
+			want:    "'sha256-qznLcsROx4GACP2dm0UCKCzCG+HiZ1guq6ZZDob/Tng='",
+		},
+		"sha384": {
+			algo:    HashSHA384,
+			content: "alert('Hello, world.');",
+			want:    "'sha384-H8BRh8j48O9oYatfu5AZzq6A9RINhZO5H16dQZngK7T62em8MUt1FLm52t+eX6xO'",
+		},
+		"sha512": {
+			algo:    "SHA512",
+			content: "alert('Hello, world.');",
+			want:    "'sha512-Q2bFTOhEALkN8hOms2FKTDLy7eugP2zFZ1T8LCvX42Fp3WoNr3bjZSAHeOsHrbV1Fu9/A0EzCinRE7Af1ofPrw=='",
+		},
+		"untrimmed": {
+			algo:    HashSHA256,
+			content: "\n  alert('Hello, world.');\n",
+			want:    "'sha256-haVyeupPm/LZ6OoOxXeAt6YukqVu0Vb0/RzugQLOdxo='",
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := HashSource(c.algo, c.content)
+			if err != nil {
+				t.Fatalf(errorString, err, nil)
+			}
+			if got != c.want {
+				t.Fatalf(errorString, got, c.want)
+			}
+		})
+	}
+}
+
+func TestHashSourceUnsupported(t *testing.T) {
+	if _, err := HashSource("md5", "alert(1)"); err == nil {
+		t.Fatalf(errorString, err, "unsupported hash algorithm error")
+	}
+}
+
+func TestIsNonceSourceAndIsHashSource(t *testing.T) {
+	cases := map[string]struct {
+		val   string
+		nonce bool
+		hash  bool
+	}{
+		"nonce":       {val: "'nonce-r4nd0m'", nonce: true},
+		"empty nonce": {val: "'nonce-'"},
+		"sha256":      {val: "'sha256-abc='", hash: true},
+		"sha512":      {val: "'sha512-abc='", hash: true},
+		"md5":         {val: "'md5-abc='"},
+		"unquoted":    {val: "nonce-r4nd0m"},
+		"keyword":     {val: "'self'"},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			if got := isNonceSource(c.val); got != c.nonce {
+				t.Fatalf(errorString, got, c.nonce)
+			}
+			if got := isHashSource(c.val); got != c.hash {
+				t.Fatalf(errorString, got, c.hash)
+			}
+		})
+	}
+}
+
+func TestSchemeSource(t *testing.T) {
+	cases := map[string]struct {
+		vals []string
+		want string
+	}{
+		"https": {
+			vals: []string{"https", "https:", " HTTPS: "},
+			want: SchemeHTTPS,
+		},
+		"custom": {
+			vals: []string{"web+app", "web+app:"},
+			want: "web+app:",
+		},
+	}
+	for name, c := range cases {
+		for i, v := range c.vals {
+			t.Run(fmt.Sprintf("%s %d", name, i), func(t *testing.T) {
+				got, err := SchemeSource(v)
+				if err != nil {
+					t.Fatalf(errorString, err, nil)
+				}
+				if got != c.want {
+					t.Fatalf(errorString, got, c.want)
+				}
+			})
+		}
+	}
+}
+
+func TestSchemeSourceInvalid(t *testing.T) {
+	for _, v := range []string{"http:/", "https://", "", ":", "1http", "ht tp"} {
+		t.Run(v, func(t *testing.T) {
+			if got, err := SchemeSource(v); err == nil {
+				t.Fatalf(errorString, got, "invalid scheme error")
+			}
+		})
+	}
+}
+
+func TestSchemeConstantsCanon(t *testing.T) {
+	schemes := []string{
+		SchemeHTTP,
+		SchemeHTTPS,
+		SchemeWS,
+		SchemeWSS,
+		SchemeData,
+		SchemeBlob,
+		SchemeMediaStream,
+		SchemeFilesystem,
+	}
+	for _, s := range schemes {
+		t.Run(s, func(t *testing.T) {
+			if got := canon(s); got != s {
+				t.Fatalf(errorString, got, s)
+			}
+		})
+	}
+}
+
+func TestValidHostSource(t *testing.T) {
+	cases := map[string]struct {
+		vals []string
+		want bool
+	}{
+		"valid": {
+			vals: []string{
+				"*",
+				"example.com",
+				"example.com.",
+				"localhost",
+				"*.example.com",
+				"*.com",
+				"https://example.com",
+				"https://example.com/path/to/app.js",
+				"https://example.com:443/",
+				"https://*.example.com:*",
+				"wss://ws.example.com",
+				"htps://example",
+			},
+			want: true,
+		},
+		"invalid": {
+			vals: []string{
+				"",
+				"*example.com",
+				"example..com",
+				".example.com",
+				"*.",
+				"https://",
+				"https://example.com:",
+				"https://example.com:44a3",
+				"ex ample.com",
+				"example.com/a;b",
+				"example_com",
+				"1http://example.com",
+			},
+			want: false,
+		},
+	}
+	for name, c := range cases {
+		for _, v := range c.vals {
+			t.Run(fmt.Sprintf("%s %s", name, v), func(t *testing.T) {
+				if got := ValidHostSource(v); got != c.want {
+					t.Fatalf(errorString, got, c.want)
+				}
+			})
+		}
+	}
+}
+
+func TestSources(t *testing.T) {
+	var s Sources
+	s.Add("self", "https://example.com")
+	s.Add("'self'", "SELF")
+	if want := (Sources{"'self'", "https://example.com"}); !reflect.DeepEqual(s, want) {
+		t.Fatalf(errorString, s, want)
+	}
+	for _, v := range []string{"self", "'self'", " Self ", "https://example.com"} {
+		if !s.Contains(v) {
+			t.Fatalf(errorString, false, true)
+		}
+	}
+	if s.Contains("none") {
+		t.Fatalf(errorString, true, false)
+	}
+	s.Remove("self")
+	s.Remove("'self'")
+	if want := (Sources{"https://example.com"}); !reflect.DeepEqual(s, want) {
+		t.Fatalf(errorString, s, want)
+	}
+}
+
+func TestSourcesCanon(t *testing.T) {
+	s := Sources{"self", " https://example.com ", "'self'", "unsafe-inline"}
+	want := Sources{"'self'", "https://example.com", "'unsafe-inline'"}
+	if got := s.Canon(); !reflect.DeepEqual(got, want) {
+		t.Fatalf(errorString, got, want)
+	}
+}
+
+func TestSourcesInterop(t *testing.T) {
+	ds := Directives{ScriptSrc: []string{"'self'"}}
+	s := Sources(ds.ScriptSrc)
+	s.Add("https://cdn.example.com")
+	ds.ScriptSrc = s
+	if got, want := Policy(ds), "script-src 'self' https://cdn.example.com;"; got != want {
+		t.Fatalf(errorString, got, want)
+	}
+}
@@ -0,0 +1,31 @@
+package csp
+
+import "html/template"
+
+// NonceFunc is the name of the template function returning the nonce set by
+// WithNonce.
+const NonceFunc = "cspNonce"
+
+// TemplateFuncs returns a template.FuncMap defining NonceFunc, which must be
+// added to a template before it is parsed. The function returns an empty
+// string until the template is bound to a nonce with WithNonce.
+func TemplateFuncs() template.FuncMap {
+	return template.FuncMap{NonceFunc: func() string { return "" }}
+}
+
+// WithNonce returns a clone of t whose NonceFunc returns nonce, typically the
+// per-request value from NonceFromContext, for use as
+//
+//	<script nonce="{{cspNonce}}">
+//
+// The template must have been parsed with TemplateFuncs. The nonce is escaped
+// like any other attribute value, and user agents decode the escaped value
+// before matching it against the policy. An error is returned if t cannot be
+// cloned because it has already been executed.
+func WithNonce(t *template.Template, nonce string) (*template.Template, error) {
+	clone, err := t.Clone()
+	if err != nil {
+		return nil, err
+	}
+	return clone.Funcs(template.FuncMap{NonceFunc: func() string { return nonce }}), nil
+}
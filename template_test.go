@@ -0,0 +1,105 @@
+package csp
+
+import (
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// scriptNonces returns the nonce attribute of every script element in the
+// HTML document, as decoded by a user agent.
+func scriptNonces(t *testing.T, document string) []string {
+	t.Helper()
+	doc, err := html.Parse(strings.NewReader(document))
+	if err != nil {
+		t.Fatalf(errorString, err, nil)
+	}
+	var nonces []string
+	for _, n := range elements(doc, atom.Script) {
+		nonce, _ := attr(n, "nonce")
+		nonces = append(nonces, nonce)
+	}
+	return nonces
+}
+
+func TestWithNonce(t *testing.T) {
+	tmpl := template.Must(template.New("page").Funcs(TemplateFuncs()).Parse(
+		`<script nonce="{{cspNonce}}">alert(1)</script>`,
+	))
+	cases := map[string]string{
+		"plain":   "r4nd0m",
+		"base64":  "a+b/c==",
+		"escaped": `"><script>`,
+	}
+	for name, nonce := range cases {
+		t.Run(name, func(t *testing.T) {
+			bound, err := WithNonce(tmpl, nonce)
+			if err != nil {
+				t.Fatalf(errorString, err, nil)
+			}
+			var b strings.Builder
+			if err := bound.Execute(&b, nil); err != nil {
+				t.Fatalf(errorString, err, nil)
+			}
+			got, want := scriptNonces(t, b.String()), []string{nonce}
+			if len(got) != 1 || got[0] != want[0] {
+				t.Fatalf(errorString, got, want)
+			}
+		})
+	}
+}
+
+func TestWithNonceUnbound(t *testing.T) {
+	tmpl := template.Must(template.New("page").Funcs(TemplateFuncs()).Parse(
+		`<script nonce="{{cspNonce}}"></script>`,
+	))
+	var b strings.Builder
+	if err := tmpl.Execute(&b, nil); err != nil {
+		t.Fatalf(errorString, err, nil)
+	}
+	want := `<script nonce=""></script>`
+	if got := b.String(); got != want {
+		t.Fatalf(errorString, got, want)
+	}
+}
+
+func TestWithNonceExecuted(t *testing.T) {
+	tmpl := template.Must(template.New("page").Funcs(TemplateFuncs()).Parse(`{{cspNonce}}`))
+	if err := tmpl.Execute(&strings.Builder{}, nil); err != nil {
+		t.Fatalf(errorString, err, nil)
+	}
+	if _, err := WithNonce(tmpl, "r4nd0m"); err == nil {
+		t.Fatalf(errorString, err, "an error")
+	}
+}
+
+func TestWithNonceHandler(t *testing.T) {
+	tmpl := template.Must(template.New("page").Funcs(TemplateFuncs()).Parse(
+		`<script nonce="{{cspNonce}}">alert(1)</script>`,
+	))
+	page := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		bound, err := WithNonce(tmpl, NonceFromContext(r.Context()))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if err := bound.Execute(w, nil); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+	rec := httptest.NewRecorder()
+	NonceHandler(Directives{DefaultSrc: []string{"self"}})(page).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	nonces := scriptNonces(t, rec.Body.String())
+	if len(nonces) != 1 || nonces[0] == "" {
+		t.Fatalf(errorString, nonces, "a single nonce")
+	}
+	want := NonceSource(nonces[0])
+	if got := rec.Header().Get(HeaderKey); !strings.Contains(got, want) {
+		t.Fatalf(errorString, got, want)
+	}
+}
@@ -0,0 +1,261 @@
+package csp
+
+import (
+	"reflect"
+	"slices"
+)
+
+// unsafeSources are the sources removed by StripUnsafe.
+var unsafeSources = []string{
+	SourceUnsafeInline,
+	SourceUnsafeEval,
+	SourceUnsafeHashes,
+	"*",
+}
+
+// StripUnsafe returns a hardened copy of ds with 'unsafe-inline',
+// 'unsafe-eval', 'unsafe-hashes', and the * wildcard removed from every
+// source-list directive, along with the removed sources keyed by directive
+// name. A directive left empty by the removal is set to 'none' rather than
+// dropped, making explicit that it allows nothing. Directives which are not
+// source lists, such as trusted-types where * allows any policy name, are
+// left untouched.
+func StripUnsafe(ds Directives) (Directives, map[string][]string) {
+	hardened := ds.Clone()
+	removed := make(map[string][]string)
+	val := reflect.ValueOf(&hardened).Elem()
+	for _, f := range fields {
+		if f.kind != reflect.Slice || slices.Contains(nonSourceLists, f.dName) {
+			continue
+		}
+		v := val.Field(f.index)
+		sources := v.Interface().([]string)
+		if len(sources) == 0 {
+			continue
+		}
+		kept := slices.DeleteFunc(sources, func(s string) bool {
+			if c := f.canon(s); slices.Contains(unsafeSources, c) {
+				removed[f.dName] = append(removed[f.dName], c)
+				return true
+			}
+			return false
+		})
+		if len(kept) == 0 {
+			kept = []string{SourceNone}
+		}
+		v.Set(reflect.ValueOf(kept))
+	}
+	return hardened, removed
+}
+
+// Minify returns a copy of ds without the fetch directives whose sources
+// equal those they fall back to when not set, shrinking the policy without
+// changing what it allows. This removes directives which repeat default-src,
+// as well as the *-src-elem and *-src-attr directives which repeat their
+// script-src or style-src. A directive is only removed when the sources it
+// would fall back to are exactly its own and no unset directive falling back
+// through it, such as worker-src through child-src, would then fall back to
+// different sources; directives which do not fall back, such as base-uri or
+// form-action, are never removed.
+func Minify(ds Directives) Directives {
+	minified := ds.Clone()
+	val := reflect.ValueOf(&minified).Elem()
+	for _, f := range fields {
+		if _, ok := fallbacks[f.dName]; ok && removable(minified, f) {
+			val.Field(f.index).SetZero()
+		}
+	}
+	return minified
+}
+
+// tightens returns true if unsetting the directive dName of ds makes a fetch
+// directive, dName itself or one falling back through it, fall back to
+// sources other than its own, rather than leaving it unrestricted.
+func tightens(ds Directives, dName string) bool {
+	without := ds
+	reflect.ValueOf(&without).Elem().FieldByName(fieldName[dName]).SetZero()
+	for d := range fallbacks {
+		before, _ := effective(ds, d)
+		if after, ok := effective(without, d); ok && !sameSet(before, after) {
+			return true
+		}
+	}
+	return false
+}
+
+// removable returns true if the fetch directive f is set in ds and unsetting
+// it changes the effective sources, see Effective, of no fetch directive,
+// including f itself and every directive falling back through it.
+func removable(ds Directives, f field) bool {
+	if sources(ds, f.dName) == nil {
+		return false
+	}
+	without := ds
+	reflect.ValueOf(&without).Elem().Field(f.index).SetZero()
+	for dName := range fallbacks {
+		before, wasSet := effective(ds, dName)
+		after, isSet := effective(without, dName)
+		if wasSet != isSet || !sameSet(before, after) {
+			return false
+		}
+	}
+	return true
+}
+
+// RedundantAgainstDefault returns the names of the fetch directives of ds
+// whose sources equal those of default-src and which would fall back to
+// default-src when not set, so that removing any one of them does not change
+// what the policy allows. A directive which falls back through another set
+// directive first, such as script-src-elem through script-src, is only
+// included if that directive's sources also equal default-src, and a
+// directive through which an unset directive falls back, such as child-src
+// for worker-src, is only included if that directive would then fall back to
+// the same sources. See Minify.
+func RedundantAgainstDefault(ds Directives) []string {
+	def := sources(ds, "default-src")
+	if def == nil {
+		return nil
+	}
+	var names []string
+	for _, f := range fields {
+		if _, ok := fallbacks[f.dName]; !ok || !sameSet(sources(ds, f.dName), def) {
+			continue
+		}
+		if removable(ds, f) {
+			names = append(names, f.dName)
+		}
+	}
+	return names
+}
+
+// Dequote returns a copy of ds with the single-quotes, and any white space
+// inside them, removed from every quoted source which is not a keyword,
+// nonce, or hash source, such as "'https://example.com'", which user agents
+// would otherwise ignore. Keyword sources are never modified. Validate reports
+// such sources as errors.
+func Dequote(ds Directives) Directives {
+	fixed := ds.Clone()
+	val := reflect.ValueOf(&fixed).Elem()
+	for _, f := range fields {
+		if f.kind != reflect.Slice {
+			continue
+		}
+		for i, s := range val.Field(f.index).Interface().([]string) {
+			if isMisquoted(s) {
+				val.Field(f.index).Index(i).SetString(trimQuotes(s))
+			}
+		}
+	}
+	return fixed
+}
+
+// ReportOnlyShadow returns a stricter copy of ds to be set under
+// ReportOnlyHeaderKey alongside ds, collecting the violations hardening ds
+// would cause before it is enforced. These transforms are applied:
+//   - If script-src is not set, it is set to the sources of default-src, so
+//     that the following apply to scripts governed by default-src.
+//   - 'unsafe-inline' is removed from script-src, script-src-elem, and
+//     script-src-attr; a directive left empty is set to 'none'.
+//   - 'strict-dynamic' is added to script-src if it contains a nonce-source or
+//     hash-source, so that only trusted scripts and those they load may run.
+//   - object-src is set to 'none' unless its effective sources, see
+//     Effective, are already 'none', blocking plugins.
+//
+// ds is not modified.
+func ReportOnlyShadow(ds Directives) Directives {
+	shadow := ds.Clone()
+	if len(shadow.ScriptSrc) == 0 {
+		shadow.ScriptSrc = slices.Clone(shadow.DefaultSrc)
+	}
+	for _, sources := range []*[]string{&shadow.ScriptSrc, &shadow.ScriptSrcElem, &shadow.ScriptSrcAttr} {
+		if len(*sources) == 0 {
+			continue
+		}
+		*sources = slices.DeleteFunc(*sources, func(s string) bool { return canon(s) == SourceUnsafeInline })
+		if len(*sources) == 0 {
+			*sources = []string{SourceNone}
+		}
+	}
+	cs := canons(shadow.ScriptSrc)
+	if !slices.Contains(cs, SourceStrictDynamic) && slices.ContainsFunc(cs, func(s string) bool {
+		return isNonceSource(s) || isHashSource(s)
+	}) {
+		shadow.ScriptSrc = append(shadow.ScriptSrc, SourceStrictDynamic)
+	}
+	if objects, _ := effective(shadow, "object-src"); !slices.Equal(objects, []string{SourceNone}) {
+		shadow.ObjectSrc = []string{SourceNone}
+	}
+	return shadow
+}
+
+// criticalDirectives are the directives SplitBySize always keeps enforced.
+var criticalDirectives = []string{
+	"base-uri",
+	"default-src",
+	"object-src",
+	"script-src",
+}
+
+// SplitBySize splits ds into a policy to enforce and a policy to set under
+// ReportOnlyHeaderKey so that the enforced policy is at most maxBytes long,
+// see Size. Source-list directives are moved to the report-only policy
+// largest first, keeping the number of moved directives small, until the
+// enforced policy fits. The most security-critical directives, base-uri,
+// default-src, object-src, and script-src, are never moved. Nor are
+// report-uri, report-to, sandbox, and valueless directives, which are either
+// needed to deliver reports or ignored in report-only policies; report-uri and
+// report-to are copied to the report-only policy when any directive is moved.
+// Extra directives may be moved.
+//
+// A fetch directive is not moved if the enforced policy would then fall back
+// to other sources for it, or for a directive falling back through it, such
+// as img-src falling back to default-src, since enforcing the fallback would
+// block what the directive allowed. A fetch directive with no fallback set is
+// moved, leaving it unrestricted in the enforced policy. The enforced policy
+// exceeds maxBytes only if the directives which are not moved do.
+func SplitBySize(ds Directives, maxBytes int) (enforce, reportOnly Directives) {
+	enforce = ds.Clone()
+	if Size(enforce) <= maxBytes {
+		return enforce, reportOnly
+	}
+	var movable []entry
+	for _, e := range entries(ds, PolicyOptions{}) {
+		name, ok := fieldName[e.name]
+		if ok && (reflect.ValueOf(ds).FieldByName(name).Kind() != reflect.Slice ||
+			slices.Contains(criticalDirectives, e.name) || e.name == "report-uri") {
+			continue
+		}
+		movable = append(movable, e)
+	}
+	slices.SortStableFunc(movable, func(a, b entry) int {
+		return len(b.name) + len(b.value) - len(a.name) - len(a.value)
+	})
+	from := reflect.ValueOf(&enforce).Elem()
+	to := reflect.ValueOf(&reportOnly).Elem()
+	for _, e := range movable {
+		if Size(enforce) <= maxBytes {
+			break
+		}
+		name, ok := fieldName[e.name]
+		if ok && tightens(enforce, e.name) {
+			continue
+		}
+		if !ok {
+			if reportOnly.Extra == nil {
+				reportOnly.Extra = make(map[string][]string)
+			}
+			reportOnly.Extra[e.name] = enforce.Extra[e.name]
+			if delete(enforce.Extra, e.name); len(enforce.Extra) == 0 {
+				enforce.Extra = nil
+			}
+			continue
+		}
+		to.FieldByName(name).Set(from.FieldByName(name))
+		from.FieldByName(name).SetZero()
+	}
+	if Size(reportOnly) > 0 {
+		reportOnly.ReportTo = enforce.ReportTo
+		reportOnly.ReportURI = slices.Clone(enforce.ReportURI)
+	}
+	return enforce, reportOnly
+}
@@ -0,0 +1,495 @@
+package csp
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+func TestStripUnsafe(t *testing.T) {
+	ds := Directives{
+		DefaultSrc:    []string{"'self'"},
+		ScriptSrc:     []string{"'self'", "unsafe-inline", "'unsafe-eval'", "https://cdn.example.com"},
+		ScriptSrcAttr: []string{"'unsafe-hashes'", "'unsafe-inline'"},
+		StyleSrc:      []string{"'self'", "'unsafe-inline'"},
+		ImgSrc:        []string{"*"},
+	}
+	original := ds.Clone()
+	wantHardened := Directives{
+		DefaultSrc:    []string{"'self'"},
+		ScriptSrc:     []string{"'self'", "https://cdn.example.com"},
+		ScriptSrcAttr: []string{"'none'"},
+		StyleSrc:      []string{"'self'"},
+		ImgSrc:        []string{"'none'"},
+	}
+	wantRemoved := map[string][]string{
+		"img-src":         {"*"},
+		"script-src":      {"'unsafe-inline'", "'unsafe-eval'"},
+		"script-src-attr": {"'unsafe-hashes'", "'unsafe-inline'"},
+		"style-src":       {"'unsafe-inline'"},
+	}
+	hardened, removed := StripUnsafe(ds)
+	if !reflect.DeepEqual(hardened, wantHardened) {
+		t.Fatalf(directivesErrorString, hardened, wantHardened)
+	}
+	if !reflect.DeepEqual(removed, wantRemoved) {
+		t.Fatalf(errorString, removed, wantRemoved)
+	}
+	if !reflect.DeepEqual(ds, original) {
+		t.Fatalf(directivesErrorString, ds, original)
+	}
+}
+
+func TestStripUnsafeNonSourceLists(t *testing.T) {
+	ds := Directives{
+		ScriptSrc:    []string{"*"},
+		ReportURI:    []string{"*"},
+		TrustedTypes: []string{"*"},
+	}
+	want := Directives{
+		ScriptSrc:    []string{"'none'"},
+		ReportURI:    []string{"*"},
+		TrustedTypes: []string{"*"},
+	}
+	if got, _ := StripUnsafe(ds); !reflect.DeepEqual(got, want) {
+		t.Fatalf(directivesErrorString, got, want)
+	}
+}
+
+func TestDequote(t *testing.T) {
+	ds := Directives{
+		DefaultSrc:   []string{"'self'", "'https://x.com'", " 'example.com' ", "' https://y.com '"},
+		ScriptSrc:    []string{"'nonce-r4nd0m'", "'sha256-abc='", "'Strict-Dynamic'", "'*.cdn.com'"},
+		TrustedTypes: []string{"'myPolicy'", "'allow-duplicates'"},
+	}
+	original := ds.Clone()
+	want := Directives{
+		DefaultSrc:   []string{"'self'", "https://x.com", "example.com", "https://y.com"},
+		ScriptSrc:    []string{"'nonce-r4nd0m'", "'sha256-abc='", "'Strict-Dynamic'", "*.cdn.com"},
+		TrustedTypes: []string{"myPolicy", "'allow-duplicates'"},
+	}
+	if got := Dequote(ds); !reflect.DeepEqual(got, want) {
+		t.Fatalf(directivesErrorString, got, want)
+	}
+	if !reflect.DeepEqual(ds, original) {
+		t.Fatalf(directivesErrorString, ds, original)
+	}
+}
+
+func TestReportOnlyShadow(t *testing.T) {
+	cases := map[string]struct {
+		ds   Directives
+		want Directives
+	}{
+		"unsafe-inline removed": {
+			ds: Directives{
+				DefaultSrc: []string{"'self'"},
+				ScriptSrc:  []string{"'self'", "unsafe-inline", "https://cdn.example.com"},
+				StyleSrc:   []string{"'self'", "'unsafe-inline'"},
+			},
+			want: Directives{
+				DefaultSrc: []string{"'self'"},
+				ObjectSrc:  []string{"'none'"},
+				ScriptSrc:  []string{"'self'", "https://cdn.example.com"},
+				StyleSrc:   []string{"'self'", "'unsafe-inline'"},
+			},
+		},
+		"strict-dynamic added with nonce": {
+			ds: Directives{
+				ObjectSrc:     []string{"'none'"},
+				ScriptSrc:     []string{"'nonce-r4nd0m'", "'unsafe-inline'"},
+				ScriptSrcAttr: []string{"'unsafe-inline'"},
+			},
+			want: Directives{
+				ObjectSrc:     []string{"'none'"},
+				ScriptSrc:     []string{"'nonce-r4nd0m'", "'strict-dynamic'"},
+				ScriptSrcAttr: []string{"'none'"},
+			},
+		},
+		"strict-dynamic not repeated": {
+			ds: Directives{
+				DefaultSrc: []string{"'none'"},
+				ScriptSrc:  []string{"'sha256-abc='", "strict-dynamic"},
+			},
+			want: Directives{
+				DefaultSrc: []string{"'none'"},
+				ScriptSrc:  []string{"'sha256-abc='", "strict-dynamic"},
+			},
+		},
+		"default-src materialized": {
+			ds: Directives{
+				DefaultSrc: []string{"'self'", "'unsafe-inline'"},
+			},
+			want: Directives{
+				DefaultSrc: []string{"'self'", "'unsafe-inline'"},
+				ObjectSrc:  []string{"'none'"},
+				ScriptSrc:  []string{"'self'"},
+			},
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			original := c.ds.Clone()
+			if got := ReportOnlyShadow(c.ds); !reflect.DeepEqual(got, c.want) {
+				t.Fatalf(directivesErrorString, got, c.want)
+			}
+			if !reflect.DeepEqual(c.ds, original) {
+				t.Fatalf(directivesErrorString, c.ds, original)
+			}
+		})
+	}
+}
+
+func TestMinify(t *testing.T) {
+	cases := map[string]struct {
+		ds   Directives
+		want Directives
+	}{
+		"redundant": {
+			ds: Directives{
+				DefaultSrc: []string{"'self'"},
+				FontSrc:    []string{"self"},
+				ImgSrc:     []string{"'self'", "'self'"},
+			},
+			want: Directives{
+				DefaultSrc: []string{"'self'"},
+			},
+		},
+		"differing": {
+			ds: Directives{
+				DefaultSrc: []string{"'self'"},
+				FontSrc:    []string{"'self'", "https://fonts.example.com"},
+			},
+			want: Directives{
+				DefaultSrc: []string{"'self'"},
+				FontSrc:    []string{"'self'", "https://fonts.example.com"},
+			},
+		},
+		"order ignored": {
+			ds: Directives{
+				DefaultSrc: []string{"'self'", "https://example.com"},
+				ImgSrc:     []string{"https://example.com", "'self'"},
+			},
+			want: Directives{
+				DefaultSrc: []string{"'self'", "https://example.com"},
+			},
+		},
+		"no default-src": {
+			ds: Directives{
+				FontSrc: []string{"'self'"},
+			},
+			want: Directives{
+				FontSrc: []string{"'self'"},
+			},
+		},
+		"non-fetch directive": {
+			ds: Directives{
+				DefaultSrc:     []string{"'self'"},
+				BaseURI:        []string{"'self'"},
+				FormAction:     []string{"'self'"},
+				FrameAncestors: []string{"'self'"},
+			},
+			want: Directives{
+				DefaultSrc:     []string{"'self'"},
+				BaseURI:        []string{"'self'"},
+				FormAction:     []string{"'self'"},
+				FrameAncestors: []string{"'self'"},
+			},
+		},
+		"redundant script family": {
+			ds: Directives{
+				DefaultSrc:    []string{"'none'"},
+				ScriptSrc:     []string{"'self'", "https://cdn.example.com"},
+				ScriptSrcAttr: []string{"'self'", "https://cdn.example.com"},
+				ScriptSrcElem: []string{"https://cdn.example.com", "'self'"},
+			},
+			want: Directives{
+				DefaultSrc: []string{"'none'"},
+				ScriptSrc:  []string{"'self'", "https://cdn.example.com"},
+			},
+		},
+		"differing script family": {
+			ds: Directives{
+				DefaultSrc:    []string{"'none'"},
+				ScriptSrc:     []string{"'self'", "https://cdn.example.com"},
+				ScriptSrcAttr: []string{"'none'"},
+				ScriptSrcElem: []string{"'self'"},
+			},
+			want: Directives{
+				DefaultSrc:    []string{"'none'"},
+				ScriptSrc:     []string{"'self'", "https://cdn.example.com"},
+				ScriptSrcAttr: []string{"'none'"},
+				ScriptSrcElem: []string{"'self'"},
+			},
+		},
+		"redundant style family": {
+			ds: Directives{
+				StyleSrc:     []string{"'self'", "'unsafe-inline'"},
+				StyleSrcAttr: []string{"'unsafe-inline'", "'self'"},
+				StyleSrcElem: []string{"'self'", "unsafe-inline"},
+			},
+			want: Directives{
+				StyleSrc: []string{"'self'", "'unsafe-inline'"},
+			},
+		},
+		"differing style family": {
+			ds: Directives{
+				StyleSrc:     []string{"'self'"},
+				StyleSrcAttr: []string{"'unsafe-inline'"},
+				StyleSrcElem: []string{"'self'", "https://fonts.example.com"},
+			},
+			want: Directives{
+				StyleSrc:     []string{"'self'"},
+				StyleSrcAttr: []string{"'unsafe-inline'"},
+				StyleSrcElem: []string{"'self'", "https://fonts.example.com"},
+			},
+		},
+		"whole family repeats default-src": {
+			ds: Directives{
+				DefaultSrc:    []string{"'self'"},
+				ScriptSrc:     []string{"'self'"},
+				ScriptSrcElem: []string{"'self'"},
+			},
+			want: Directives{
+				DefaultSrc: []string{"'self'"},
+			},
+		},
+		"dependent fallback": {
+			ds: Directives{
+				DefaultSrc: []string{"'self'"},
+				ChildSrc:   []string{"'self'"},
+				ScriptSrc:  []string{"'nonce-abc'"},
+			},
+			want: Directives{
+				DefaultSrc: []string{"'self'"},
+				ChildSrc:   []string{"'self'"},
+				ScriptSrc:  []string{"'nonce-abc'"},
+			},
+		},
+		"intermediate fallback": {
+			ds: Directives{
+				DefaultSrc: []string{"'self'"},
+				ChildSrc:   []string{"https://frames.example.com"},
+				WorkerSrc:  []string{"'self'"},
+			},
+			want: Directives{
+				DefaultSrc: []string{"'self'"},
+				ChildSrc:   []string{"https://frames.example.com"},
+				WorkerSrc:  []string{"'self'"},
+			},
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			original := c.ds.Clone()
+			if got := Minify(c.ds); !reflect.DeepEqual(got, c.want) {
+				t.Fatalf(directivesErrorString, got, c.want)
+			}
+			if !reflect.DeepEqual(c.ds, original) {
+				t.Fatalf(directivesErrorString, c.ds, original)
+			}
+		})
+	}
+}
+
+func TestRedundantAgainstDefault(t *testing.T) {
+	cases := map[string]struct {
+		ds   Directives
+		want []string
+	}{
+		"no default-src": {
+			ds: Directives{FontSrc: []string{"'self'"}},
+		},
+		"font-src equal, object-src differing": {
+			ds: Directives{
+				DefaultSrc: []string{"'self'", "https://example.com"},
+				FontSrc:    []string{"https://example.com", "self"},
+				ObjectSrc:  []string{"'none'"},
+			},
+			want: []string{"font-src"},
+		},
+		"not fetch directives": {
+			ds: Directives{
+				BaseURI:    []string{"'self'"},
+				DefaultSrc: []string{"'self'"},
+				FormAction: []string{"'self'"},
+				ImgSrc:     []string{"'self'"},
+			},
+			want: []string{"img-src"},
+		},
+		"worker-src falls back through child-src": {
+			ds: Directives{
+				DefaultSrc: []string{"'self'"},
+				ChildSrc:   []string{"'self'"},
+				ScriptSrc:  []string{"'nonce-abc'"},
+			},
+		},
+		"frame-src and worker-src fall back through child-src": {
+			ds: Directives{
+				DefaultSrc: []string{"'self'"},
+				ChildSrc:   []string{"'self'"},
+				WorkerSrc:  []string{"'self'"},
+			},
+			want: []string{"child-src", "worker-src"},
+		},
+		"fenced-frame-src falls back through frame-src": {
+			ds: Directives{
+				DefaultSrc: []string{"'self'"},
+				ChildSrc:   []string{"https://frames.example.com"},
+				FrameSrc:   []string{"'self'"},
+			},
+		},
+		"fallback through script-src": {
+			ds: Directives{
+				DefaultSrc:    []string{"'self'"},
+				ScriptSrc:     []string{"'self'", "https://cdn.example.com"},
+				ScriptSrcElem: []string{"'self'"},
+			},
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			if got := RedundantAgainstDefault(c.ds); !reflect.DeepEqual(got, c.want) {
+				t.Fatalf(errorString, got, c.want)
+			}
+		})
+	}
+}
+
+func TestSplitBySize(t *testing.T) {
+	ds := Directives{
+		BaseURI:   []string{"'none'"},
+		FontSrc:   []string{"https://fonts.example.com"},
+		ImgSrc:    []string{"'self'", "https://images.example.com", "https://cdn.example.com"},
+		ObjectSrc: []string{"'none'"},
+		ReportURI: []string{"/csp"},
+		ScriptSrc: []string{"'self'", "https://scripts.example.com", "https://analytics.example.com"},
+		StyleSrc:  []string{"'self'", "https://styles.example.com"},
+		Sandbox:   "allow-scripts",
+		Extra:     map[string][]string{"foo-src": {"https://foo.example.com"}},
+	}
+	cases := map[string]struct {
+		maxBytes            int
+		enforce, reportOnly Directives
+	}{
+		"under budget": {
+			maxBytes: Size(ds),
+			enforce:  ds,
+		},
+		"largest moved first": {
+			maxBytes: Size(ds) - 1,
+			enforce: Directives{
+				BaseURI:   []string{"'none'"},
+				FontSrc:   []string{"https://fonts.example.com"},
+				ObjectSrc: []string{"'none'"},
+				ReportURI: []string{"/csp"},
+				ScriptSrc: []string{"'self'", "https://scripts.example.com", "https://analytics.example.com"},
+				StyleSrc:  []string{"'self'", "https://styles.example.com"},
+				Sandbox:   "allow-scripts",
+				Extra:     map[string][]string{"foo-src": {"https://foo.example.com"}},
+			},
+			reportOnly: Directives{
+				ImgSrc:    []string{"'self'", "https://images.example.com", "https://cdn.example.com"},
+				ReportURI: []string{"/csp"},
+			},
+		},
+		"critical kept": {
+			maxBytes: 0,
+			enforce: Directives{
+				BaseURI:   []string{"'none'"},
+				ObjectSrc: []string{"'none'"},
+				ReportURI: []string{"/csp"},
+				ScriptSrc: []string{"'self'", "https://scripts.example.com", "https://analytics.example.com"},
+				Sandbox:   "allow-scripts",
+			},
+			reportOnly: Directives{
+				FontSrc:   []string{"https://fonts.example.com"},
+				ImgSrc:    []string{"'self'", "https://images.example.com", "https://cdn.example.com"},
+				ReportURI: []string{"/csp"},
+				StyleSrc:  []string{"'self'", "https://styles.example.com"},
+				Extra:     map[string][]string{"foo-src": {"https://foo.example.com"}},
+			},
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			enforce, reportOnly := SplitBySize(ds, c.maxBytes)
+			if !reflect.DeepEqual(enforce, c.enforce) {
+				t.Fatalf(directivesErrorString, enforce, c.enforce)
+			}
+			if !reflect.DeepEqual(reportOnly, c.reportOnly) {
+				t.Fatalf(directivesErrorString, reportOnly, c.reportOnly)
+			}
+		})
+	}
+}
+
+func TestSplitBySizeBudget(t *testing.T) {
+	var ds Directives
+	for i := 0; i < 20; i++ {
+		ds.ImgSrc = append(ds.ImgSrc, fmt.Sprintf("https://img%d.example.com", i))
+		ds.MediaSrc = append(ds.MediaSrc, fmt.Sprintf("https://m%d.example.com", i))
+	}
+	maxBytes := 600
+	enforce, reportOnly := SplitBySize(ds, maxBytes)
+	if got := Size(enforce); got > maxBytes {
+		t.Fatalf(errorString, got, maxBytes)
+	}
+	want := Directives{ImgSrc: ds.ImgSrc}
+	if !reflect.DeepEqual(reportOnly, want) {
+		t.Fatalf(directivesErrorString, reportOnly, want)
+	}
+}
+
+func TestSplitBySizeFallback(t *testing.T) {
+	cases := map[string]struct {
+		ds                  Directives
+		enforce, reportOnly Directives
+	}{
+		"falls back to default-src": {
+			ds: Directives{
+				DefaultSrc: []string{"'self'"},
+				ImgSrc:     []string{"https://cdn.example.com"},
+			},
+			enforce: Directives{
+				DefaultSrc: []string{"'self'"},
+				ImgSrc:     []string{"https://cdn.example.com"},
+			},
+		},
+		"falls back through": {
+			ds: Directives{
+				ChildSrc:  []string{"https://frames.example.com"},
+				ScriptSrc: []string{"'self'"},
+			},
+			enforce: Directives{
+				ChildSrc:  []string{"https://frames.example.com"},
+				ScriptSrc: []string{"'self'"},
+			},
+		},
+		"same as fallback": {
+			ds: Directives{
+				DefaultSrc: []string{"'self'"},
+				ImgSrc:     []string{"'self'"},
+			},
+			enforce:    Directives{DefaultSrc: []string{"'self'"}},
+			reportOnly: Directives{ImgSrc: []string{"'self'"}},
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			enforce, reportOnly := SplitBySize(c.ds, 25)
+			if !reflect.DeepEqual(enforce, c.enforce) {
+				t.Fatalf(directivesErrorString, enforce, c.enforce)
+			}
+			if !reflect.DeepEqual(reportOnly, c.reportOnly) {
+				t.Fatalf(directivesErrorString, reportOnly, c.reportOnly)
+			}
+			for _, dName := range []string{"img-src", "worker-src"} {
+				if got, want := Effective(enforce, dName), Effective(c.ds, dName); !slices.Equal(got, want) {
+					t.Fatalf(errorString, got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,520 @@
+package csp
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// Severity describes how serious a Finding is.
+type Severity int
+
+// Severities of a Finding, from least to most serious.
+const (
+	SeverityWarning Severity = iota
+	SeverityError
+)
+
+// SeverityInfo is the Severity of a Finding which is informational only. It
+// is less serious than SeverityWarning, and its value sorts below the other
+// severities without changing theirs.
+const SeverityInfo Severity = -1
+
+// String returns the lowercase name of s.
+func (s Severity) String() string {
+	switch s {
+	case SeverityInfo:
+		return "info"
+	case SeverityWarning:
+		return "warning"
+	case SeverityError:
+		return "error"
+	}
+	return fmt.Sprintf("Severity(%d)", int(s))
+}
+
+// Finding describes a problem with a policy. Directive is the name of the
+// directive the problem relates to and is empty if it relates to the policy
+// as a whole.
+type Finding struct {
+	Severity  Severity
+	Directive string
+	Message   string
+}
+
+// Error returns a description of f, allowing a Finding to be used as an error.
+func (f Finding) Error() string {
+	if f.Directive == "" {
+		return fmt.Sprintf("csp: %s: %s", f.Severity, f.Message)
+	}
+	return fmt.Sprintf("csp: %s: %s: %s", f.Severity, f.Directive, f.Message)
+}
+
+// rule reports the Findings of a single check against ds.
+type rule func(ds Directives) []Finding
+
+// DefaultMaxSize is the policy size in bytes above which Validate warns
+// unless configured otherwise, matching a common server and CDN header limit.
+const DefaultMaxSize = 8192
+
+// DefaultMaxSources is the number of sources in a directive above which
+// Validate warns unless configured otherwise.
+const DefaultMaxSources = 20
+
+// ValidateOption configures Validate.
+type ValidateOption func(*validateConfig)
+
+// validateConfig holds the settings applied by ValidateOptions.
+type validateConfig struct {
+	maxSize     int
+	maxSources  int
+	groups      []string
+	knownGroups bool
+}
+
+// MaxSize returns a ValidateOption that makes Validate warn when the policy
+// is larger than n bytes instead of DefaultMaxSize.
+func MaxSize(n int) ValidateOption {
+	return func(c *validateConfig) {
+		c.maxSize = n
+	}
+}
+
+// MaxSources returns a ValidateOption that makes Validate warn when a
+// directive has more than n sources instead of DefaultMaxSources.
+func MaxSources(n int) ValidateOption {
+	return func(c *validateConfig) {
+		c.maxSources = n
+	}
+}
+
+// ReportingGroups returns a ValidateOption declaring the reporting endpoint
+// groups defined alongside the policy, e.g. by ReportingEndpointsHeader or
+// ReportToHeader. Validate then warns if report-to names any other group,
+// instead of reminding that the group must be defined.
+func ReportingGroups(groups ...string) ValidateOption {
+	return func(c *validateConfig) {
+		c.groups = append(c.groups, groups...)
+		c.knownGroups = true
+	}
+}
+
+// rules returns the checks run by Validate, in order.
+func (c validateConfig) rules() []rule {
+	return []rule{
+		checkNoneMixed,
+		checkUnsafeInline,
+		checkUnsafeHashes,
+		checkMisquoted,
+		checkReportSample,
+		checkFallbacks,
+		checkDefaultNone,
+		c.checkReportTo,
+		checkReportBoth,
+		checkSandbox,
+		checkWebRTC,
+		checkHostSources,
+		checkBroadSources,
+		c.checkSourceCount,
+		c.checkSize,
+	}
+}
+
+// Validate returns Findings describing common misconfigurations of ds. Errors
+// describe directives that will not behave as written; warnings describe
+// policies that are weak or depend on something outside the policy; info
+// findings describe behaviour which is likely intended but easy to misread.
+func Validate(ds Directives, opts ...ValidateOption) []Finding {
+	c := validateConfig{maxSize: DefaultMaxSize, maxSources: DefaultMaxSources}
+	for _, opt := range opts {
+		opt(&c)
+	}
+	var findings []Finding
+	for _, r := range c.rules() {
+		findings = append(findings, r(ds)...)
+	}
+	return findings
+}
+
+// Lint returns the Findings of Validate for the serialized policy. If policy
+// cannot be parsed, see Parse, a single Finding of SeverityError describing
+// the problem is returned instead.
+func Lint(policy string, opts ...ValidateOption) []Finding {
+	ds, err := Parse(policy)
+	if err != nil {
+		return []Finding{{
+			Severity: SeverityError,
+			Message:  strings.TrimPrefix(err.Error(), "csp: "),
+		}}
+	}
+	return Validate(ds, opts...)
+}
+
+// PolicyStrict returns the same policy string as Policy, or an error if
+// Validate reports any Findings of SeverityError for ds. The error joins every
+// such Finding; warnings do not cause an error.
+func PolicyStrict(ds Directives) (string, error) {
+	var errs []error
+	for _, f := range Validate(ds) {
+		if f.Severity == SeverityError {
+			errs = append(errs, f)
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return "", err
+	}
+	return Policy(ds), nil
+}
+
+// eachSources calls fn with the directive name and canonicalized sources of
+// every non-empty slice field of ds.
+func eachSources(ds Directives, fn func(dName string, sources []string)) {
+	val := reflect.ValueOf(ds)
+	for i := 0; i < val.NumField(); i++ {
+		field := val.Field(i)
+		if field.Kind() != reflect.Slice || field.Len() == 0 {
+			continue
+		}
+		name := val.Type().Field(i).Name
+		fn(CName[name], dedup(canonSources(name, field.Interface().([]string))))
+	}
+}
+
+// checkNoneMixed reports directives where 'none' is combined with other
+// sources, in which case 'none' has no effect.
+func checkNoneMixed(ds Directives) []Finding {
+	var findings []Finding
+	eachSources(ds, func(dName string, sources []string) {
+		if len(sources) > 1 && slices.Contains(sources, SourceNone) {
+			findings = append(findings, Finding{
+				Severity:  SeverityError,
+				Directive: dName,
+				Message:   "'none' is combined with other sources and is ignored",
+			})
+		}
+	})
+	return findings
+}
+
+// checkUnsafeInline reports directives where 'unsafe-inline' is ignored by
+// user agents because a nonce-source or hash-source is also present.
+func checkUnsafeInline(ds Directives) []Finding {
+	var findings []Finding
+	eachSources(ds, func(dName string, sources []string) {
+		if !slices.Contains(sources, SourceUnsafeInline) {
+			return
+		}
+		if slices.ContainsFunc(sources, isNonceSource) || slices.ContainsFunc(sources, isHashSource) {
+			findings = append(findings, Finding{
+				Severity:  SeverityWarning,
+				Directive: dName,
+				Message:   "'unsafe-inline' is ignored because a nonce-source or hash-source is present",
+			})
+		}
+	})
+	return findings
+}
+
+// checkUnsafeHashes reports 'unsafe-hashes' without any hash-source, in which
+// case it has no effect, and 'unsafe-hashes' in script-src, where it is
+// likely meant for script-src-attr.
+func checkUnsafeHashes(ds Directives) []Finding {
+	var findings []Finding
+	eachSources(ds, func(dName string, sources []string) {
+		if !slices.Contains(sources, SourceUnsafeHashes) {
+			return
+		}
+		if !slices.ContainsFunc(sources, isHashSource) {
+			findings = append(findings, Finding{
+				Severity:  SeverityWarning,
+				Directive: dName,
+				Message:   "'unsafe-hashes' has no effect because no hash-source is present",
+			})
+		}
+		if dName == CName["ScriptSrc"] {
+			findings = append(findings, Finding{
+				Severity:  SeverityWarning,
+				Directive: dName,
+				Message:   "'unsafe-hashes' is usually intended for script-src-attr, to allow hashed event handlers only",
+			})
+		}
+	})
+	return findings
+}
+
+// checkMisquoted reports quoted sources which are not keyword, nonce, or hash
+// sources, which user agents ignore. See Dequote.
+func checkMisquoted(ds Directives) []Finding {
+	var findings []Finding
+	eachSources(ds, func(dName string, sources []string) {
+		for _, s := range sources {
+			if isMisquoted(s) {
+				findings = append(findings, Finding{
+					Severity:  SeverityError,
+					Directive: dName,
+					Message:   fmt.Sprintf("%s is quoted but is not a keyword, nonce, or hash source; remove the quotes", s),
+				})
+			}
+		}
+	})
+	return findings
+}
+
+// reportSampleDirectives are the directives where 'report-sample' has an
+// effect, including default-src which they fall back to.
+var reportSampleDirectives = []string{
+	"default-src",
+	"script-src",
+	"script-src-attr",
+	"script-src-elem",
+	"style-src",
+	"style-src-attr",
+	"style-src-elem",
+}
+
+// checkReportSample reports 'report-sample' in directives where it has no
+// effect, since samples are only reported for inline scripts and styles.
+func checkReportSample(ds Directives) []Finding {
+	var findings []Finding
+	eachSources(ds, func(dName string, sources []string) {
+		if slices.Contains(sources, SourceReportSample) && !slices.Contains(reportSampleDirectives, dName) {
+			findings = append(findings, Finding{
+				Severity:  SeverityWarning,
+				Directive: dName,
+				Message:   "'report-sample' has no effect outside script-src, style-src, and their variants",
+			})
+		}
+	})
+	return findings
+}
+
+// checkFallbacks reports a missing default-src, and a missing script-src when
+// there is no default-src to fall back to.
+func checkFallbacks(ds Directives) []Finding {
+	if len(ds.DefaultSrc) > 0 {
+		return nil
+	}
+	findings := []Finding{{
+		Severity:  SeverityWarning,
+		Directive: CName["DefaultSrc"],
+		Message:   "default-src is not set, so fetch directives that are not set are unrestricted",
+	}}
+	if len(ds.ScriptSrc) == 0 && len(ds.ScriptSrcElem) == 0 {
+		findings = append(findings, Finding{
+			Severity:  SeverityWarning,
+			Directive: CName["ScriptSrc"],
+			Message:   "script-src is not set and there is no default-src, so scripts are unrestricted",
+		})
+	}
+	return findings
+}
+
+// checkDefaultNone notes the fetch directives which are not set and so fall
+// back to a default-src of 'none', blocking every resource they govern.
+func checkDefaultNone(ds Directives) []Finding {
+	if !slices.Equal(sources(ds, "default-src"), []string{SourceNone}) {
+		return nil
+	}
+	var blocked []string
+	for _, f := range fields {
+		chain, ok := fallbacks[f.dName]
+		if !ok || sources(ds, f.dName) != nil {
+			continue
+		}
+		if i := slices.IndexFunc(chain, func(d string) bool { return sources(ds, d) != nil }); chain[i] == "default-src" {
+			blocked = append(blocked, f.dName)
+		}
+	}
+	if len(blocked) == 0 {
+		return nil
+	}
+	return []Finding{{
+		Severity:  SeverityInfo,
+		Directive: CName["DefaultSrc"],
+		Message:   fmt.Sprintf("default-src is 'none', so these directives which are not set block everything: %s", strings.Join(blocked, ", ")),
+	}}
+}
+
+// checkReportTo reports a report-to group which is not among the configured
+// ReportingGroups, or, if none are configured, reminds that the group must be
+// defined by a separate response header.
+func (c validateConfig) checkReportTo(ds Directives) []Finding {
+	group := canon(ds.ReportTo)
+	if group == "" || slices.Contains(c.groups, group) {
+		return nil
+	}
+	msg := fmt.Sprintf("report-to names %q, which must also be defined by a Reporting-Endpoints or Report-To response header", group)
+	if c.knownGroups {
+		msg = fmt.Sprintf("report-to names %q, which is not a defined reporting group", group)
+	}
+	return []Finding{{
+		Severity:  SeverityWarning,
+		Directive: CName["ReportTo"],
+		Message:   msg,
+	}}
+}
+
+// checkReportBoth notes that report-uri is ignored by user agents supporting
+// report-to when both are set, which is the intended pattern for supporting
+// older user agents.
+func checkReportBoth(ds Directives) []Finding {
+	if len(ds.ReportURI) == 0 || canon(ds.ReportTo) == "" {
+		return nil
+	}
+	return []Finding{{
+		Severity:  SeverityInfo,
+		Directive: CName["ReportURI"],
+		Message:   "report-uri is ignored by user agents supporting report-to and only serves older user agents",
+	}}
+}
+
+// checkSandbox reports sandbox tokens which are not recognized. An empty
+// sandbox value is valid and is the most restrictive sandbox.
+func checkSandbox(ds Directives) []Finding {
+	var findings []Finding
+	for _, token := range strings.Fields(ds.Sandbox) {
+		if !ValidSandboxToken(token) {
+			findings = append(findings, Finding{
+				Severity:  SeverityError,
+				Directive: CName["Sandbox"],
+				Message:   fmt.Sprintf("%q is not a valid sandbox token", token),
+			})
+		}
+	}
+	return findings
+}
+
+// checkWebRTC reports a webrtc value which is neither 'allow' nor 'block',
+// since user agents ignore the directive otherwise.
+func checkWebRTC(ds Directives) []Finding {
+	if canon(ds.WebRTC) == "" || ValidWebRTC(ds.WebRTC) {
+		return nil
+	}
+	return []Finding{{
+		Severity:  SeverityError,
+		Directive: CName["WebRTC"],
+		Message:   fmt.Sprintf("%q is not a valid value; use %s or %s", ds.WebRTC, WebRTCAllow, WebRTCBlock),
+	}}
+}
+
+// nonSourceLists are directives whose values are not source lists.
+var nonSourceLists = []string{
+	"plugin-types",
+	"report-uri",
+	"require-trusted-types-for",
+	"trusted-types",
+}
+
+// commonSchemes are the schemes expected in the host-sources of a policy.
+var commonSchemes = []string{"http", "https", "ws", "wss"}
+
+// checkHostSources reports sources which are not valid host-sources, host
+// wildcards covering an entire top-level domain, and uncommon schemes which
+// may be typos.
+func checkHostSources(ds Directives) []Finding {
+	var findings []Finding
+	eachSources(ds, func(dName string, sources []string) {
+		if slices.Contains(nonSourceLists, dName) {
+			return
+		}
+		for _, s := range sources {
+			if strings.HasPrefix(s, "'") || isSchemeSource(s) {
+				continue
+			}
+			var msg string
+			scheme, _, hasScheme := strings.Cut(s, "://")
+			switch host := hostOf(s); {
+			case !ValidHostSource(s):
+				msg = fmt.Sprintf("%q is not a valid host-source", s)
+			case hasScheme && !slices.Contains(commonSchemes, strings.ToLower(scheme)):
+				msg = fmt.Sprintf("%q has an uncommon scheme %q; check for typos", s, scheme)
+			case strings.HasPrefix(host, "*.") && !strings.Contains(strings.TrimSuffix(host[2:], "."), "."):
+				msg = fmt.Sprintf("%q allows every host of a top-level domain", s)
+			default:
+				continue
+			}
+			findings = append(findings, Finding{Severity: SeverityWarning, Directive: dName, Message: msg})
+		}
+	})
+	return findings
+}
+
+// scriptDirectives are the directives controlling which scripts and plugins
+// may run.
+var scriptDirectives = []string{
+	"default-src",
+	"object-src",
+	"script-src",
+	"script-src-attr",
+	"script-src-elem",
+}
+
+// checkBroadSources reports sources in scriptDirectives which allow scripts
+// from any host, i.e. * and wildcard hosts such as https://*, and data:, from
+// which arbitrary scripts can be injected. default-src is only checked when
+// script-src or object-src is not set, since plugins loaded under object-src
+// can run scripts too and default-src does not control either otherwise.
+func checkBroadSources(ds Directives) []Finding {
+	var findings []Finding
+	eachSources(ds, func(dName string, sources []string) {
+		if !slices.Contains(scriptDirectives, dName) || dName == "default-src" && len(ds.ScriptSrc) > 0 && len(ds.ObjectSrc) > 0 {
+			return
+		}
+		for _, s := range sources {
+			var msg string
+			switch {
+			case s == "*" || hostOf(s) == "*":
+				msg = fmt.Sprintf("%q allows scripts from any host, which disables the directive's protection", s)
+			case strings.EqualFold(s, SchemeData):
+				msg = fmt.Sprintf("%q allows scripts from data: URLs, a common XSS vector", s)
+			default:
+				continue
+			}
+			findings = append(findings, Finding{Severity: SeverityWarning, Directive: dName, Message: msg})
+		}
+	})
+	return findings
+}
+
+// hostOf returns the host of the host-source s, without its scheme, port, or
+// path.
+func hostOf(s string) string {
+	if _, after, ok := strings.Cut(s, "://"); ok {
+		s = after
+	}
+	if i := strings.IndexAny(s, ":/"); i >= 0 {
+		s = s[:i]
+	}
+	return s
+}
+
+// checkSourceCount reports directives with more than the configured maximum
+// number of sources, since long allowlists are hard to audit and often allow
+// more than intended; nonces or hashes are usually a better fit.
+func (c validateConfig) checkSourceCount(ds Directives) []Finding {
+	var findings []Finding
+	eachSources(ds, func(dName string, sources []string) {
+		if n := len(sources); n > c.maxSources {
+			findings = append(findings, Finding{
+				Severity:  SeverityWarning,
+				Directive: dName,
+				Message:   fmt.Sprintf("%s has %d sources, exceeding the limit of %d; consider nonces or hashes", dName, n, c.maxSources),
+			})
+		}
+	})
+	return findings
+}
+
+// checkSize reports a policy larger than the configured maximum size, which
+// some servers, proxies, and CDNs reject or truncate.
+func (c validateConfig) checkSize(ds Directives) []Finding {
+	if n := Size(ds); n > c.maxSize {
+		return []Finding{{
+			Severity: SeverityWarning,
+			Message:  fmt.Sprintf("policy is %d bytes, exceeding the %d byte limit", n, c.maxSize),
+		}}
+	}
+	return nil
+}
@@ -0,0 +1,587 @@
+package csp
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// summary is a Finding without its Message, for concise comparisons.
+type summary struct {
+	Severity  Severity
+	Directive string
+}
+
+// summarize returns the summary of every Finding in findings.
+func summarize(findings []Finding) []summary {
+	var ss []summary
+	for _, f := range findings {
+		ss = append(ss, summary{f.Severity, f.Directive})
+	}
+	return ss
+}
+
+func TestValidate(t *testing.T) {
+	cases := map[string]struct {
+		directives Directives
+		want       []summary
+	}{
+		"basic": {
+			directives: Directives{
+				DefaultSrc:     []string{"self"},
+				FormAction:     []string{"self"},
+				FrameAncestors: []string{"self"},
+			},
+		},
+		"none mixed": {
+			directives: Directives{
+				DefaultSrc: []string{"self"},
+				ObjectSrc:  []string{"none", "https://example.com"},
+			},
+			want: []summary{{SeverityError, "object-src"}},
+		},
+		"none alone": {
+			directives: Directives{
+				DefaultSrc: []string{"none"},
+			},
+			want: []summary{{SeverityInfo, "default-src"}},
+		},
+		"default-src none fully set": {
+			directives: Directives{
+				DefaultSrc:  []string{"none"},
+				ChildSrc:    []string{"none"},
+				ConnectSrc:  []string{"self"},
+				FontSrc:     []string{"self"},
+				ImgSrc:      []string{"self"},
+				ManifestSrc: []string{"self"},
+				MediaSrc:    []string{"self"},
+				ObjectSrc:   []string{"none"},
+				PrefetchSrc: []string{"self"},
+				ScriptSrc:   []string{"self"},
+				StyleSrc:    []string{"self"},
+			},
+		},
+		"unsafe-inline with nonce": {
+			directives: Directives{
+				DefaultSrc: []string{"self"},
+				ScriptSrc:  []string{"unsafe-inline", NonceSource("r4nd0m")},
+			},
+			want: []summary{{SeverityWarning, "script-src"}},
+		},
+		"unsafe-inline with hash": {
+			directives: Directives{
+				DefaultSrc: []string{"self"},
+				StyleSrc:   []string{"unsafe-inline", "'sha256-abc='"},
+			},
+			want: []summary{{SeverityWarning, "style-src"}},
+		},
+		"unsafe-inline alone": {
+			directives: Directives{
+				DefaultSrc: []string{"self"},
+				ScriptSrc:  []string{"unsafe-inline"},
+			},
+		},
+		"unsafe-hashes with hash": {
+			directives: Directives{
+				DefaultSrc:    []string{"self"},
+				ScriptSrcAttr: []string{"unsafe-hashes", "'sha256-abc='"},
+			},
+		},
+		"unsafe-hashes without hash": {
+			directives: Directives{
+				DefaultSrc:   []string{"self"},
+				StyleSrcAttr: []string{"unsafe-hashes"},
+			},
+			want: []summary{{SeverityWarning, "style-src-attr"}},
+		},
+		"unsafe-hashes in script-src": {
+			directives: Directives{
+				DefaultSrc: []string{"self"},
+				ScriptSrc:  []string{"unsafe-hashes", "'sha256-abc='"},
+			},
+			want: []summary{{SeverityWarning, "script-src"}},
+		},
+		"unsafe-hashes in script-src without hash": {
+			directives: Directives{
+				DefaultSrc: []string{"self"},
+				ScriptSrc:  []string{"unsafe-hashes"},
+			},
+			want: []summary{{SeverityWarning, "script-src"}, {SeverityWarning, "script-src"}},
+		},
+		"misquoted host": {
+			directives: Directives{
+				DefaultSrc: []string{"self", "'https://x.com'"},
+			},
+			want: []summary{{SeverityError, "default-src"}},
+		},
+		"quoted keywords": {
+			directives: Directives{
+				DefaultSrc:             []string{"'self'", "'SELF'"},
+				ScriptSrc:              []string{"'strict-dynamic'", "'nonce-r4nd0m'", "'sha256-abc='"},
+				RequireTrustedTypesFor: []string{"'script'"},
+				TrustedTypes:           []string{"'allow-duplicates'"},
+			},
+		},
+		"report-sample in img-src": {
+			directives: Directives{
+				DefaultSrc: []string{"self"},
+				ImgSrc:     []string{"self", "report-sample"},
+			},
+			want: []summary{{SeverityWarning, "img-src"}},
+		},
+		"report-sample in script and style": {
+			directives: Directives{
+				DefaultSrc:   []string{"self", "report-sample"},
+				ScriptSrc:    []string{"self", "'report-sample'"},
+				StyleSrcElem: []string{"self", "report-sample"},
+			},
+		},
+		"missing default-src": {
+			directives: Directives{
+				ScriptSrc: []string{"self"},
+			},
+			want: []summary{{SeverityWarning, "default-src"}},
+		},
+		"missing default-src and script-src": {
+			directives: Directives{
+				ImgSrc: []string{"self"},
+			},
+			want: []summary{{SeverityWarning, "default-src"}, {SeverityWarning, "script-src"}},
+		},
+		"report-to": {
+			directives: Directives{
+				DefaultSrc: []string{"self"},
+				ReportTo:   "csp-endpoint",
+			},
+			want: []summary{{SeverityWarning, "report-to"}},
+		},
+		"report-uri and report-to": {
+			directives: Directives{
+				DefaultSrc: []string{"self"},
+				ReportTo:   "csp-endpoint",
+				ReportURI:  []string{"https://example.com/csp-reports"},
+			},
+			want: []summary{{SeverityWarning, "report-to"}, {SeverityInfo, "report-uri"}},
+		},
+		"report-uri only": {
+			directives: Directives{
+				DefaultSrc: []string{"self"},
+				ReportURI:  []string{"https://example.com/csp-reports"},
+			},
+		},
+		"sandbox": {
+			directives: Directives{
+				DefaultSrc: []string{"self"},
+				Sandbox:    "allow-forms Allow-Scripts",
+			},
+		},
+		"sandbox typo": {
+			directives: Directives{
+				DefaultSrc: []string{"self"},
+				Sandbox:    "allow-forms allow-scrpits",
+			},
+			want: []summary{{SeverityError, "sandbox"}},
+		},
+		"webrtc": {
+			directives: Directives{
+				DefaultSrc: []string{"self"},
+				WebRTC:     "Block",
+			},
+		},
+		"host sources": {
+			directives: Directives{
+				DefaultSrc: []string{"self", "*", "https://*.example.com:443/app/", "data:"},
+				ObjectSrc:  []string{"none"},
+				ReportURI:  []string{"/csp-reports?x=1"},
+				ScriptSrc:  []string{"self"},
+			},
+		},
+		"broad script-src": {
+			directives: Directives{
+				DefaultSrc: []string{"self"},
+				ScriptSrc:  []string{"*"},
+			},
+			want: []summary{{SeverityWarning, "script-src"}},
+		},
+		"broad scheme wildcard": {
+			directives: Directives{
+				DefaultSrc:    []string{"self"},
+				ScriptSrcElem: []string{"https://*"},
+			},
+			want: []summary{{SeverityWarning, "script-src-elem"}},
+		},
+		"data script-src": {
+			directives: Directives{
+				DefaultSrc: []string{"self"},
+				ScriptSrc:  []string{"self", "data:"},
+			},
+			want: []summary{{SeverityWarning, "script-src"}},
+		},
+		"broad object-src": {
+			directives: Directives{
+				DefaultSrc: []string{"self"},
+				ObjectSrc:  []string{"*"},
+			},
+			want: []summary{{SeverityWarning, "object-src"}},
+		},
+		"broad default-src fallback": {
+			directives: Directives{
+				DefaultSrc: []string{"*"},
+				ObjectSrc:  []string{"none"},
+			},
+			want: []summary{{SeverityWarning, "default-src"}},
+		},
+		"broad default-src object-src fallback": {
+			directives: Directives{
+				DefaultSrc: []string{"*"},
+				ScriptSrc:  []string{"self"},
+			},
+			want: []summary{{SeverityWarning, "default-src"}},
+		},
+		"broad img-src": {
+			directives: Directives{
+				DefaultSrc: []string{"self"},
+				ImgSrc:     []string{"*", "data:"},
+			},
+		},
+		"invalid host sources": {
+			directives: Directives{
+				DefaultSrc: []string{"self", "example..com", "*example.com"},
+			},
+			want: []summary{{SeverityWarning, "default-src"}, {SeverityWarning, "default-src"}},
+		},
+		"top-level domain wildcard": {
+			directives: Directives{
+				DefaultSrc: []string{"self"},
+				ImgSrc:     []string{"*.com", "https://*.co:443"},
+			},
+			want: []summary{{SeverityWarning, "img-src"}, {SeverityWarning, "img-src"}},
+		},
+		"uncommon scheme": {
+			directives: Directives{
+				DefaultSrc: []string{"self", "htps://example.com"},
+			},
+			want: []summary{{SeverityWarning, "default-src"}},
+		},
+		"webrtc invalid": {
+			directives: Directives{
+				DefaultSrc: []string{"self"},
+				WebRTC:     "maybe",
+			},
+			want: []summary{{SeverityError, "webrtc"}},
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			if got := summarize(Validate(c.directives)); !reflect.DeepEqual(got, c.want) {
+				t.Fatalf(errorString, got, c.want)
+			}
+		})
+	}
+}
+
+func TestFindingError(t *testing.T) {
+	cases := map[string]struct {
+		finding Finding
+		want    string
+	}{
+		"directive": {
+			finding: Finding{SeverityError, "object-src", "bad"},
+			want:    "csp: error: object-src: bad",
+		},
+		"policy": {
+			finding: Finding{SeverityWarning, "", "bad"},
+			want:    "csp: warning: bad",
+		},
+		"info": {
+			finding: Finding{SeverityInfo, "report-uri", "note"},
+			want:    "csp: info: report-uri: note",
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			if got := c.finding.Error(); got != c.want {
+				t.Fatalf(errorString, got, c.want)
+			}
+		})
+	}
+}
+
+func TestSeverityValues(t *testing.T) {
+	if SeverityWarning != 0 || SeverityError != 1 {
+		t.Fatalf(errorString, []int{int(SeverityWarning), int(SeverityError)}, []int{0, 1})
+	}
+	if !(SeverityInfo < SeverityWarning && SeverityWarning < SeverityError) {
+		t.Fatalf(errorString, SeverityInfo, "less serious than warning")
+	}
+}
+
+func TestValidateSandboxMessage(t *testing.T) {
+	findings := Validate(Directives{DefaultSrc: []string{"self"}, Sandbox: "allow-scrpits"})
+	if len(findings) != 1 || !strings.Contains(findings[0].Message, `"allow-scrpits"`) {
+		t.Fatalf(errorString, findings, `a finding naming "allow-scrpits"`)
+	}
+}
+
+func TestValidateUnsafeHashesMessage(t *testing.T) {
+	findings := Validate(Directives{DefaultSrc: []string{"self"}, ScriptSrc: []string{"unsafe-hashes", "'sha256-abc='"}})
+	if len(findings) != 1 || !strings.Contains(findings[0].Message, "script-src-attr") {
+		t.Fatalf(errorString, findings, "a finding suggesting script-src-attr")
+	}
+}
+
+func TestValidateBroadSourceMessage(t *testing.T) {
+	findings := Validate(Directives{DefaultSrc: []string{"self"}, ScriptSrc: []string{"https://*"}})
+	if len(findings) != 1 || !strings.Contains(findings[0].Message, `"https://*"`) {
+		t.Fatalf(errorString, findings, `a finding naming "https://*"`)
+	}
+}
+
+func TestValidateReportingGroups(t *testing.T) {
+	ds := Directives{
+		DefaultSrc: []string{"self"},
+		ReportTo:   "csp-endpoint",
+		ReportURI:  []string{"https://example.com/csp-reports"},
+	}
+	cases := map[string]struct {
+		opts    []ValidateOption
+		want    []summary
+		message string
+	}{
+		"defined": {
+			opts: []ValidateOption{ReportingGroups("default", "csp-endpoint")},
+			want: []summary{{SeverityInfo, "report-uri"}},
+		},
+		"undefined": {
+			opts:    []ValidateOption{ReportingGroups("default")},
+			want:    []summary{{SeverityWarning, "report-to"}, {SeverityInfo, "report-uri"}},
+			message: "not a defined reporting group",
+		},
+		"none defined": {
+			opts:    []ValidateOption{ReportingGroups()},
+			want:    []summary{{SeverityWarning, "report-to"}, {SeverityInfo, "report-uri"}},
+			message: "not a defined reporting group",
+		},
+		"not configured": {
+			want:    []summary{{SeverityWarning, "report-to"}, {SeverityInfo, "report-uri"}},
+			message: "Reporting-Endpoints",
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			findings := Validate(ds, c.opts...)
+			if got := summarize(findings); !reflect.DeepEqual(got, c.want) {
+				t.Fatalf(errorString, got, c.want)
+			}
+			if c.message != "" && !strings.Contains(findings[0].Message, c.message) {
+				t.Fatalf(errorString, findings[0].Message, c.message)
+			}
+		})
+	}
+}
+
+func TestValidateDefaultNone(t *testing.T) {
+	ds := Directives{
+		DefaultSrc: []string{"none"},
+		ScriptSrc:  []string{"self"},
+	}
+	findings := Validate(ds)
+	want := []summary{{SeverityInfo, "default-src"}}
+	if got := summarize(findings); !reflect.DeepEqual(got, want) {
+		t.Fatalf(errorString, got, want)
+	}
+	wantMsg := "default-src is 'none', so these directives which are not set block everything: " +
+		"child-src, connect-src, fenced-frame-src, font-src, frame-src, img-src, manifest-src, " +
+		"media-src, object-src, prefetch-src, style-src, style-src-attr, style-src-elem"
+	if got := findings[0].Message; got != wantMsg {
+		t.Fatalf(errorString, got, wantMsg)
+	}
+}
+
+func TestValidateSize(t *testing.T) {
+	ds := Directives{
+		DefaultSrc: []string{"self"},
+		ImgSrc:     []string{"https://images.example.com"},
+	}
+	cases := map[string]struct {
+		opts []ValidateOption
+		want []summary
+	}{
+		"default limit": {},
+		"under limit": {
+			opts: []ValidateOption{MaxSize(Size(ds))},
+		},
+		"over limit": {
+			opts: []ValidateOption{MaxSize(Size(ds) - 1)},
+			want: []summary{{SeverityWarning, ""}},
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			if got := summarize(Validate(ds, c.opts...)); !reflect.DeepEqual(got, c.want) {
+				t.Fatalf(errorString, got, c.want)
+			}
+		})
+	}
+}
+
+func TestValidateSourceCount(t *testing.T) {
+	sources := func(n int) []string {
+		var ss []string
+		for i := 0; i < n; i++ {
+			ss = append(ss, fmt.Sprintf("https://img%d.example.com", i))
+		}
+		return ss
+	}
+	cases := map[string]struct {
+		directives Directives
+		opts       []ValidateOption
+		want       []summary
+	}{
+		"at default limit": {
+			directives: Directives{DefaultSrc: []string{"self"}, ImgSrc: sources(20)},
+		},
+		"over default limit": {
+			directives: Directives{DefaultSrc: []string{"self"}, ImgSrc: sources(25)},
+			want:       []summary{{SeverityWarning, "img-src"}},
+		},
+		"duplicates counted once": {
+			directives: Directives{DefaultSrc: []string{"self"}, ImgSrc: append(sources(20), sources(5)...)},
+		},
+		"configured limit": {
+			directives: Directives{DefaultSrc: []string{"self"}, ImgSrc: sources(5)},
+			opts:       []ValidateOption{MaxSources(4)},
+			want:       []summary{{SeverityWarning, "img-src"}},
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			if got := summarize(Validate(c.directives, c.opts...)); !reflect.DeepEqual(got, c.want) {
+				t.Fatalf(errorString, got, c.want)
+			}
+		})
+	}
+}
+
+func TestValidateSourceCountMessage(t *testing.T) {
+	var ss []string
+	for i := 0; i < 25; i++ {
+		ss = append(ss, fmt.Sprintf("https://img%d.example.com", i))
+	}
+	findings := Validate(Directives{DefaultSrc: []string{"self"}, ImgSrc: ss})
+	if len(findings) != 1 || !strings.Contains(findings[0].Message, "img-src has 25 sources") {
+		t.Fatalf(errorString, findings, "a finding naming img-src and 25 sources")
+	}
+}
+
+func TestValidateSizeDefault(t *testing.T) {
+	ds := Directives{DefaultSrc: []string{"self"}}
+	for i := 0; Size(ds) <= DefaultMaxSize; i++ {
+		ds.ImgSrc = append(ds.ImgSrc, fmt.Sprintf("https://img%d.example.com/%s", i, strings.Repeat("a", 1000)))
+	}
+	want := []summary{{SeverityWarning, ""}}
+	if got := summarize(Validate(ds)); !reflect.DeepEqual(got, want) {
+		t.Fatalf(errorString, got, want)
+	}
+}
+
+func TestLint(t *testing.T) {
+	cases := map[string]struct {
+		policy string
+		want   []summary
+	}{
+		"basic": {
+			policy: Basic(),
+		},
+		"weak": {
+			policy: "script-src 'unsafe-inline' *",
+			want: []summary{
+				{SeverityWarning, "default-src"},
+				{SeverityWarning, "script-src"},
+			},
+		},
+		"malformed": {
+			policy: "default-src 'self'; script_src *",
+			want:   []summary{{SeverityError, ""}},
+		},
+		"duplicate": {
+			policy: "default-src 'self'; default-src *",
+			want:   []summary{{SeverityError, ""}},
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			if got := summarize(Lint(c.policy)); !reflect.DeepEqual(got, c.want) {
+				t.Fatalf(errorString, got, c.want)
+			}
+		})
+	}
+}
+
+func TestLintParseError(t *testing.T) {
+	want := `csp: error: malformed directive name "script_src"`
+	findings := Lint("script_src *")
+	if len(findings) != 1 || findings[0].Error() != want {
+		t.Fatalf(errorString, findings, want)
+	}
+}
+
+func FuzzLint(f *testing.F) {
+	f.Add(Basic())
+	f.Add("script-src 'unsafe-inline' *")
+	f.Add("default-src 'self'; script_src *;;, sandbox allow-scrpits")
+	f.Fuzz(func(t *testing.T, policy string) {
+		Lint(policy)
+	})
+}
+
+func TestPolicyStrict(t *testing.T) {
+	cases := map[string]struct {
+		directives Directives
+		want       string
+		err        []Finding
+	}{
+		"valid": {
+			directives: Directives{
+				DefaultSrc: []string{"self"},
+			},
+			want: "default-src 'self';",
+		},
+		"warnings only": {
+			directives: Directives{
+				ScriptSrc: []string{"self", "unsafe-inline", NonceSource("r4nd0m")},
+			},
+			want: "script-src 'self' 'unsafe-inline' 'nonce-r4nd0m';",
+		},
+		"errors": {
+			directives: Directives{
+				DefaultSrc: []string{"none", "self"},
+				Sandbox:    "allow-scrpits",
+				WebRTC:     "maybe",
+			},
+			err: []Finding{
+				{SeverityError, "default-src", "'none' is combined with other sources and is ignored"},
+				{SeverityError, "sandbox", `"allow-scrpits" is not a valid sandbox token`},
+				{SeverityError, "webrtc", `"maybe" is not a valid value; use 'allow' or 'block'`},
+			},
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := PolicyStrict(c.directives)
+			if got != c.want {
+				t.Fatalf(errorString, got, c.want)
+			}
+			for _, f := range c.err {
+				if !errors.Is(err, f) {
+					t.Fatalf(errorString, err, f)
+				}
+			}
+			if c.err == nil && err != nil {
+				t.Fatalf(errorString, err, nil)
+			}
+		})
+	}
+}
@@ -0,0 +1,24 @@
+package csp
+
+import (
+	"fmt"
+	"strings"
+)
+
+// FrameAncestorsFromXFO returns the frame-ancestors sources equivalent to the
+// X-Frame-Options header value xfo: DENY becomes 'none' and SAMEORIGIN
+// becomes 'self'. Values are case-insensitive. An error is returned for the
+// obsolete ALLOW-FROM, which user agents ignore and whose origin should be
+// listed in frame-ancestors instead, and for any other value.
+func FrameAncestorsFromXFO(xfo string) ([]string, error) {
+	v := strings.ToUpper(strings.TrimSpace(xfo))
+	switch {
+	case v == "DENY":
+		return []string{SourceNone}, nil
+	case v == "SAMEORIGIN":
+		return []string{SourceSelf}, nil
+	case strings.HasPrefix(v, "ALLOW-FROM"):
+		return nil, fmt.Errorf("csp: X-Frame-Options %q is obsolete; list the allowed origin in frame-ancestors", xfo)
+	}
+	return nil, fmt.Errorf("csp: invalid X-Frame-Options %q", xfo)
+}
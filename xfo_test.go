@@ -0,0 +1,51 @@
+package csp
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFrameAncestorsFromXFO(t *testing.T) {
+	cases := map[string]struct {
+		xfo  string
+		want []string
+	}{
+		"deny":       {xfo: "DENY", want: []string{SourceNone}},
+		"sameorigin": {xfo: "SAMEORIGIN", want: []string{SourceSelf}},
+		"lower case": {xfo: " sameorigin ", want: []string{SourceSelf}},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := FrameAncestorsFromXFO(c.xfo)
+			if err != nil {
+				t.Fatalf(errorString, err, nil)
+			}
+			if !reflect.DeepEqual(got, c.want) {
+				t.Fatalf(errorString, got, c.want)
+			}
+		})
+	}
+}
+
+func TestFrameAncestorsFromXFOErrors(t *testing.T) {
+	cases := map[string]struct {
+		xfo  string
+		want string
+	}{
+		"allow-from": {xfo: "ALLOW-FROM https://example.com", want: "obsolete"},
+		"empty":      {xfo: "", want: "invalid"},
+		"unknown":    {xfo: "ALLOWALL", want: "invalid"},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := FrameAncestorsFromXFO(c.xfo)
+			if err == nil || !strings.Contains(err.Error(), c.want) {
+				t.Fatalf(errorString, err, c.want)
+			}
+			if got != nil {
+				t.Fatalf(errorString, got, nil)
+			}
+		})
+	}
+}